@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// logEntry is a request read from an access log.
+type logEntry struct {
+	time      time.Time
+	method    string
+	uri       string // path and query
+	userAgent string
+	referer   string
+}
+
+// combinedRE matches the nginx and Apache combined log format, and the
+// common log format it extends.
+var combinedRE = regexp.MustCompile(`^\S+ \S+ \S+ \[([^\]]+)\] "(\S+) (\S+)[^"]*" \d{3} \S+(?: "([^"]*)" "([^"]*)")?`)
+
+const combinedTimeLayout = "02/Jan/2006:15:04:05 -0700"
+
+// parseCombinedLine parses a line in combined or common log format.
+func parseCombinedLine(line string) (logEntry, error) {
+	m := combinedRE.FindStringSubmatch(line)
+	if m == nil {
+		return logEntry{}, fmt.Errorf("not in combined log format: %q", line)
+	}
+	t, err := time.Parse(combinedTimeLayout, m[1])
+	if err != nil {
+		return logEntry{}, err
+	}
+	return logEntry{time: t, method: m[2], uri: m[3], referer: dash(m[4]), userAgent: dash(m[5])}, nil
+}
+
+// dash returns s, or "" for the "-" that logs use for a missing value.
+func dash(s string) string {
+	if s == "-" {
+		return ""
+	}
+	return s
+}
+
+// jsonLogFields are the names JSON access logs commonly use for each field,
+// in order of preference.
+var jsonLogFields = map[string][]string{
+	"time":       {"time", "timestamp", "@timestamp", "time_local", "time_iso8601", "ts"},
+	"method":     {"method", "request_method"},
+	"uri":        {"uri", "request_uri", "path", "url"},
+	"request":    {"request"}, // "GET /path HTTP/1.1"
+	"user_agent": {"user_agent", "http_user_agent", "userAgent"},
+	"referer":    {"referer", "http_referer", "referrer"},
+}
+
+// parseJSONLogLine parses a line of a JSON access log. Times may be RFC 3339,
+// in the combined log format, or Unix seconds.
+func parseJSONLogLine(line []byte) (logEntry, error) {
+	var obj map[string]any
+	if err := json.Unmarshal(line, &obj); err != nil {
+		return logEntry{}, err
+	}
+	field := func(name string) any {
+		for _, key := range jsonLogFields[name] {
+			if v, ok := obj[key]; ok {
+				return v
+			}
+		}
+		return nil
+	}
+	str := func(name string) string {
+		s, _ := field(name).(string)
+		return s
+	}
+
+	e := logEntry{method: str("method"), uri: str("uri"), userAgent: dash(str("user_agent")), referer: dash(str("referer"))}
+	if req := strings.Fields(str("request")); len(req) >= 2 && (e.method == "" || e.uri == "") {
+		e.method, e.uri = req[0], req[1]
+	}
+	if e.method == "" {
+		e.method = http.MethodGet
+	}
+	if e.uri == "" {
+		return logEntry{}, fmt.Errorf("no request uri in %s", line)
+	}
+
+	switch t := field("time").(type) {
+	case float64:
+		e.time = unixTime(t)
+	case string:
+		var err error
+		if e.time, err = parseLogTime(t); err != nil {
+			return logEntry{}, err
+		}
+	default:
+		return logEntry{}, fmt.Errorf("no time in %s", line)
+	}
+	return e, nil
+}
+
+// parseLogTime parses a logged time, in RFC 3339, combined log format or
+// Unix seconds.
+func parseLogTime(s string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339Nano, combinedTimeLayout} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	if sec, err := strconv.ParseFloat(s, 64); err == nil {
+		return unixTime(sec), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", s)
+}
+
+func unixTime(sec float64) time.Time {
+	whole := math.Floor(sec)
+	return time.Unix(int64(whole), int64((sec-whole)*1e9))
+}
+
+// logReplay is the request set of an access log. Requests to the same
+// endpoint from the same client share a target.
+type logReplay struct {
+	targets []*target
+	order   []int           // target of each request, in the order they were logged
+	offsets []time.Duration // of each request from the first
+}
+
+// loadAccessLog reads an access log, in combined log format or as JSON lines,
+// as requests against base, the URL that replaces the logged host. Headers
+// given with -H take precedence over logged ones.
+func loadAccessLog(path, base string, defaults targetDefaults) (*logReplay, error) {
+	baseURL, err := url.Parse(base)
+	if err != nil || baseURL.Scheme == "" || baseURL.Host == "" {
+		return nil, fmt.Errorf("base url must include scheme and host: %q", base)
+	}
+
+	f, err := os.Open(path) //nolint:gosec // User explicitly specified file path via -replay flag
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []logEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var e logEntry
+		if strings.HasPrefix(text, "{") {
+			e, err = parseJSONLogLine([]byte(text))
+		} else {
+			e, err = parseCombinedLine(text)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("%s has no requests", path)
+	}
+	// logs are written as requests complete, so they may be slightly out of order
+	slices.SortStableFunc(entries, func(a, b logEntry) int { return a.time.Compare(b.time) })
+
+	replay := &logReplay{}
+	byKey := map[logEntry]int{}
+	for _, e := range entries {
+		offset := e.time.Sub(entries[0].time)
+		e.time = time.Time{} // so that entries only differ by request
+		if defaults.header.Get("User-Agent") != "" {
+			e.userAgent = "" // overridden by -H
+		}
+		if defaults.header.Get("Referer") != "" {
+			e.referer = ""
+		}
+		idx, ok := byKey[e]
+		if !ok {
+			t, err := newTarget(logSpec(e, baseURL), logDefaults(e, defaults))
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s %s: %w", path, e.method, e.uri, err)
+			}
+			idx = len(replay.targets)
+			byKey[e] = idx
+			replay.targets = append(replay.targets, t)
+		}
+		replay.order = append(replay.order, idx)
+		replay.offsets = append(replay.offsets, offset)
+	}
+	return replay, nil
+}
+
+// logSpec returns the target of a logged request, sent to base.
+func logSpec(e logEntry, base *url.URL) targetSpec {
+	uri := e.uri
+	if u, err := url.Parse(uri); err == nil && u.IsAbs() {
+		uri = u.RequestURI() // logged by a proxy
+	}
+	spec := targetSpec{
+		Name:    e.method + " " + uri,
+		URL:     strings.TrimSuffix(base.String(), "/") + uri,
+		Headers: map[string]string{},
+	}
+	if e.userAgent != "" {
+		spec.Headers["User-Agent"] = e.userAgent
+	}
+	if e.referer != "" {
+		spec.Headers["Referer"] = e.referer
+	}
+	return spec
+}
+
+// logDefaults returns defaults with the method of a logged request.
+func logDefaults(e logEntry, defaults targetDefaults) targetDefaults {
+	defaults.method = e.method
+	return defaults
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseCombinedLine(t *testing.T) {
+	e, err := parseCombinedLine(`203.0.113.7 - alice [01/May/2024:10:00:01 +0000] "POST /api/cart?x=1 HTTP/1.1" 201 512 "https://example.com/" "Mozilla/5.0 (X11)"`)
+	if err != nil {
+		t.Fatalf("parseCombinedLine failed: %v", err)
+	}
+	want := logEntry{
+		time:      time.Date(2024, 5, 1, 10, 0, 1, 0, time.UTC),
+		method:    "POST",
+		uri:       "/api/cart?x=1",
+		referer:   "https://example.com/",
+		userAgent: "Mozilla/5.0 (X11)",
+	}
+	if !e.time.Equal(want.time) || e.method != want.method || e.uri != want.uri || e.referer != want.referer || e.userAgent != want.userAgent {
+		t.Errorf("expected %+v, got %+v", want, e)
+	}
+
+	// common log format has no referer or user agent
+	e, err = parseCombinedLine(`127.0.0.1 - - [01/May/2024:10:00:01 -0700] "GET / HTTP/1.0" 200 -`)
+	if err != nil {
+		t.Fatalf("parseCombinedLine failed: %v", err)
+	}
+	if e.uri != "/" || e.userAgent != "" || e.time.UTC().Hour() != 17 {
+		t.Errorf("unexpected entry %+v", e)
+	}
+
+	if _, err := parseCombinedLine("not a log line"); err == nil {
+		t.Error("expected error for an unknown format")
+	}
+}
+
+func TestParseJSONLogLine(t *testing.T) {
+	tests := []struct {
+		line   string
+		method string
+		uri    string
+		time   time.Time
+	}{
+		{`{"time": "2024-05-01T10:00:01.5Z", "method": "PUT", "uri": "/a", "user_agent": "curl"}`, "PUT", "/a", time.Date(2024, 5, 1, 10, 0, 1, 5e8, time.UTC)},
+		{`{"time_local": "01/May/2024:10:00:01 +0000", "request": "DELETE /b HTTP/2.0"}`, "DELETE", "/b", time.Date(2024, 5, 1, 10, 0, 1, 0, time.UTC)},
+		{`{"ts": 1714557601.25, "path": "/c"}`, "GET", "/c", time.Unix(1714557601, 25e7)},
+		{`{"timestamp": "1714557601", "request_method": "HEAD", "request_uri": "/d"}`, "HEAD", "/d", time.Unix(1714557601, 0)},
+	}
+	for _, tt := range tests {
+		e, err := parseJSONLogLine([]byte(tt.line))
+		if err != nil {
+			t.Errorf("parseJSONLogLine(%s) failed: %v", tt.line, err)
+			continue
+		}
+		if e.method != tt.method || e.uri != tt.uri || !e.time.Equal(tt.time) {
+			t.Errorf("parseJSONLogLine(%s): expected %s %s at %s, got %s %s at %s", tt.line, tt.method, tt.uri, tt.time, e.method, e.uri, e.time)
+		}
+	}
+
+	for _, line := range []string{`{"time": "2024-05-01T10:00:01Z"}`, `{"uri": "/a"}`, `{"uri": "/a", "time": "yesterday"}`, `{`} {
+		if _, err := parseJSONLogLine([]byte(line)); err == nil {
+			t.Errorf("expected error for %s", line)
+		}
+	}
+}
+
+func TestLoadAccessLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	content := `10.0.0.1 - - [01/May/2024:10:00:02 +0000] "GET /b HTTP/1.1" 200 10 "-" "curl/8"
+10.0.0.1 - - [01/May/2024:10:00:00 +0000] "GET http://old.example.com/a?q=1 HTTP/1.1" 200 10 "-" "curl/8"
+
+{"time": "2024-05-01T10:00:01Z", "request": "GET /b HTTP/1.1", "user_agent": "curl/8"}
+10.0.0.2 - - [01/May/2024:10:00:03 +0000] "GET /b HTTP/1.1" 200 10 "-" "Mozilla/5.0"
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to create log file: %v", err)
+	}
+
+	header := http.Header{"User-Agent": {"boop"}}
+	replay, err := loadAccessLog(path, "https://staging.example.com/", targetDefaults{method: "POST", header: header})
+	if err != nil {
+		t.Fatalf("loadAccessLog failed: %v", err)
+	}
+
+	// with -H setting the user agent, the requests to /b are all the same
+	if len(replay.targets) != 2 {
+		t.Fatalf("expected 2 distinct targets, got %d", len(replay.targets))
+	}
+	a := replay.targets[0]
+	if a.req.URL.String() != "https://staging.example.com/a?q=1" || a.req.Method != http.MethodGet || a.name != "GET /a?q=1" {
+		t.Errorf("expected GET /a?q=1 against the base url, got %s %s named %q", a.req.Method, a.req.URL, a.name)
+	}
+	if a.req.Header.Get("User-Agent") != "boop" {
+		t.Errorf("expected -H to override the logged user agent, got %q", a.req.Header.Get("User-Agent"))
+	}
+
+	if want := []int{0, 1, 1, 1}; len(replay.order) != len(want) || replay.order[0] != 0 || replay.order[3] != 1 {
+		t.Errorf("expected order %v, got %v", want, replay.order)
+	}
+	for i, want := range []time.Duration{0, time.Second, 2 * time.Second, 3 * time.Second} {
+		if replay.offsets[i] != want {
+			t.Errorf("expected request %d at %s, got %s", i, want, replay.offsets[i])
+		}
+	}
+
+	// without it, each client's user agent is kept
+	replay, err = loadAccessLog(path, "https://staging.example.com", targetDefaults{method: "GET"})
+	if err != nil {
+		t.Fatalf("loadAccessLog failed: %v", err)
+	}
+	if len(replay.targets) != 3 || replay.targets[2].req.Header.Get("User-Agent") != "Mozilla/5.0" {
+		t.Errorf("expected 3 targets, the last from Mozilla/5.0, got %d", len(replay.targets))
+	}
+}
+
+func TestLoadAccessLogErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	if err := os.WriteFile(path, []byte("garbage\n"), 0644); err != nil {
+		t.Fatalf("failed to create log file: %v", err)
+	}
+	if _, err := loadAccessLog(path, "https://staging.example.com", targetDefaults{}); err == nil {
+		t.Error("expected error for an unparseable log")
+	}
+	if _, err := loadAccessLog(path, "staging.example.com", targetDefaults{}); err == nil {
+		t.Error("expected error for a base url without a scheme")
+	}
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatalf("failed to create log file: %v", err)
+	}
+	if _, err := loadAccessLog(path, "https://staging.example.com", targetDefaults{}); err == nil {
+		t.Error("expected error for an empty log")
+	}
+}
@@ -1,17 +1,12 @@
 package main
 
 import (
-	"bytes"
-	"cmp"
 	"context"
-	"crypto/tls"
 	"flag"
 	"fmt"
-	"io"
+	"maps"
 	"math"
-	"math/rand/v2"
 	"net/http"
-	"net/url"
 	"os"
 	"os/signal"
 	"slices"
@@ -23,82 +18,348 @@ import (
 
 var (
 	totalReq          = flag.Int("n", math.MaxInt-1, "Total requests to perform")
+	duration          = flag.Duration("z", 0, "Duration of the run, e.g. 30s or 5m (0 = until -n requests are sent)")
 	concur            = flag.Int("c", 10, "Concurrency level, a.k.a., number of workers")
 	rps               = flag.Float64("q", 0, "Per‑worker RPS (0 = unlimited)")
+	rate              = flag.Float64("rate", 0, "Global arrival rate in requests/sec, independent of response times (0 = off)")
+	maxConcur         = flag.Int("max-c", 1000, "Maximum number of workers the pool may grow to under -rate, -stages or -search")
+	stagesFlag        = flag.String("stages", "", "Load stages as duration:rps ramps, e.g. 1m:200,5m:200,30s:0")
+	search            = flag.Bool("search", false, "Step up the arrival rate until the SLO is violated, and report the highest sustainable rate")
+	searchStepRate    = flag.Float64("search-step", 10, "Arrival rate increase per search step, in requests/sec")
+	searchTime        = flag.Duration("search-time", 10*time.Second, "Duration of each search step")
+	sloP99            = flag.Duration("slo-p99", 250*time.Millisecond, "Search SLO: maximum p99 response time")
+	sloErrors         = flag.Float64("slo-errors", 1, "Search SLO: maximum error rate, in percent")
 	method            = flag.String("m", "GET", "HTTP method")
 	data              = flag.String("d", "", "Request body. Use @file to read a file")
 	timeout           = flag.Duration("t", 30*time.Second, "Per‑request timeout")
 	insecure          = flag.Bool("k", false, "Skip TLS certificate verification")
+	certFile          = flag.String("cert", "", "Client certificate file for mutual TLS, PEM")
+	keyFile           = flag.String("key", "", "Private key file of -cert, PEM (default the -cert file)")
+	caFile            = flag.String("cacert", "", "CA certificates file to verify servers with instead of the system's, PEM")
+	tlsMin            = flag.String("tls-min", "", "Minimum TLS version: 1.0, 1.1, 1.2 or 1.3")
+	tlsMax            = flag.String("tls-max", "", "Maximum TLS version: 1.0, 1.1, 1.2 or 1.3")
+	ciphers           = flag.String("ciphers", "", "Comma-separated TLS 1.2 cipher suites, e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256")
+	tlsResume         = flag.Bool("tls-resume", false, "Resume TLS sessions on new connections, like browsers, for cheaper handshakes.\nOff by default, so that every new connection makes a full handshake")
+	serverName        = flag.String("sni", "", "TLS server name to send and verify the certificate against, instead of the URL's host")
 	h2                = flag.Bool("h2", true, "Enable HTTP/2")
 	disableKeepAlives = flag.Bool("no-keepalive", false, "Disable HTTP keep-alives")
 	noRedirect        = flag.Bool("no-redirect", false, "Do not follow redirects")
+	successFlag       = flag.String("success", "2xx,3xx", "Status codes or classes of successful responses, e.g. 2xx or 2xx,404; others count as failed.\nA -check status:... replaces the default")
+	cookieJar         = flag.Bool("cookies", false, "Give each worker its own cookie jar, so that it keeps cookies set by responses like a distinct user")
+	isolate           = flag.Bool("isolate", false, "Give each worker its own cookie jar and connection pool, like a distinct client")
 	showTrace         = flag.Bool("trace", false, "Output per request connection trace")
 	live              = flag.Bool("live", false, "Display live metrics graph")
+	output            = flag.String("o", "text", "Output format of the summary: text or json")
+	templating        = flag.Bool("template", false, "Render {{...}} templates in the URL, headers and body of each request: uuid, randInt MIN MAX, seq, now, workerID, env \"NAME\"")
+	feedPath          = flag.String("feed", "", "File of template variables, {{.name}}, one row per request: CSV with a header line (.csv) or JSON objects (.jsonl, .ndjson). Implies -template")
+	feedMode          = flag.String("feed-mode", "sequential", "How -feed rows are used: sequential, random, or per-worker (each worker keeps one row)")
+	feedEnd           = flag.String("feed-end", "wrap", "At the end of a sequential -feed: wrap around to the first row, or stop the run")
+	harPath           = flag.String("har", "", "Replay the requests of a HAR file in order, instead of <url>")
+	harTiming         = flag.Bool("har-timing", false, "Send -har requests at their recorded offsets, repeating the recording")
+	replayPath        = flag.String("replay", "", "Replay the requests of an access log, in combined log format or JSON lines, instead of <url>")
+	replayBase        = flag.String("replay-base", "", "Base URL that -replay requests are sent to, e.g. https://staging.example.com")
+	replaySpeed       = flag.Float64("replay-speed", 1, "Speed of -replay relative to the logged timing, e.g. 2 for twice as fast (0 = as fast as possible)")
+	curlCmd           = flag.String("curl", "", "Import the request of a curl command line, e.g. from \"Copy as cURL\": its method, headers, body, -u, -b, -k and --compressed.\nFlags given explicitly take precedence")
+	scenarioPath      = flag.String("scenario", "", "Run a YAML scenario of steps, each a templated request that may capture variables from its response for later steps.\nEach job runs every step, so -n, -rate and -stages count scenario runs")
+	targetsPath       = flag.String("targets", "", "File of targets, one JSON object per line with url, and optionally method, headers, body, weight and name")
+	exportPath        = flag.String("export", "", "Stream every request's record to a file, as CSV (.csv) or NDJSON (.ndjson, .jsonl)")
+	exportFmt         = flag.String("export-format", "", "Format of the -export file: csv or ndjson (default from the file extension)")
+	savePath          = flag.String("save", "", "Save the results of the run to a file, for a later -compare")
+	comparePath       = flag.String("compare", "", "Compare the results with a baseline saved by -save, and flag regressions")
+	compareTolerance  = flag.Float64("compare-tolerance", 5, "Smallest change in latency or requests/sec, in percent, that -compare flags as a regression")
+	precision         = flag.Int("precision", 3, "Significant digits kept by latency histograms (1-4); more digits use more memory")
 	headers           headerSlice
+	thresholds        thresholdSlice
+	checks            checkSlice
+	resolves          resolveSlice
+	connectTo         connectToSlice
 )
 
 func main() {
 	flag.Var(&headers, "H", "Custom header. Repeatable.")
+	flag.Var(&checks, "check", "Check that each response must pass, or count as failed: status:200,3xx, contains:TEXT, regex:RE,\njson:PATH=VALUE, max-size:SIZE or header:NAME=VALUE. Repeatable.")
+	flag.Var(&resolves, "resolve", "Connect to HOST on PORT at ADDR, as HOST:PORT:ADDR, keeping the Host header and TLS server name. Repeatable.")
+	flag.Var(&connectTo, "connect-to", "Connect to HOST2:PORT2 instead of HOST1:PORT1, as HOST1:PORT1:HOST2:PORT2, keeping the Host header and\nTLS server name. Empty fields match any host or port, or keep the original. Repeatable.")
+	flag.Var(&thresholds, "assert", "Threshold that must hold for the run to pass, e.g. p95<300ms, errors<0.5%, rps>1000 or 5xx==0.\nRepeatable or comma-separated. boop exits with status 2 if any fail.")
 
 	flag.Parse()
-	if flag.NArg() != 1 {
-		fmt.Println("Usage: boop [options] <url>")
+	if flag.NArg() == 0 && *targetsPath == "" && *harPath == "" && *replayPath == "" && *curlCmd == "" && *scenarioPath == "" {
+		fmt.Println("Usage: boop [options] <url> [url...]")
 		flag.PrintDefaults()
 		os.Exit(1)
 	}
-	targetURL := flag.Arg(0)
 	if *concur <= 0 || *totalReq <= 0 || *concur > *totalReq {
 		fmt.Println("n must be ≥ c and both > 0")
 		os.Exit(1)
 	}
+	if *duration < 0 {
+		fmt.Println("z must be ≥ 0")
+		os.Exit(1)
+	}
+	if *output != "text" && *output != "json" {
+		fmt.Println("o must be text or json")
+		os.Exit(1)
+	}
+	if *output == "json" && *live {
+		// the live view writes to stdout too, so the JSON could not be parsed
+		fmt.Println("o json cannot be combined with live")
+		os.Exit(1)
+	}
+	if *precision < 1 || *precision > 4 {
+		fmt.Println("precision must be between 1 and 4")
+		os.Exit(1)
+	}
+	if *rate < 0 || (*rate > 0 && *rps > 0) {
+		fmt.Println("rate must be ≥ 0 and cannot be combined with q")
+		os.Exit(1)
+	}
+	var baseline *savedRun
+	if *comparePath != "" {
+		var err error
+		baseline, err = loadRun(*comparePath)
+		if err != nil {
+			fmt.Printf("invalid baseline: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// flags given on the command line, rather than left at their defaults
+	explicit := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	var curlReq *curlRequest
+	if *curlCmd != "" {
+		// the imported method, headers and body become those of every target
+		if flag.NArg() > 0 || *targetsPath != "" {
+			fmt.Println("curl cannot be combined with urls or targets")
+			os.Exit(1)
+		}
+		var err error
+		curlReq, err = parseCurl(*curlCmd)
+		if err != nil {
+			fmt.Printf("invalid curl command: %v\n", err)
+			os.Exit(1)
+		}
+		curlReq.apply(explicit)
+	}
 
 	bodyBytes, err := loadBody(*data)
 	if err != nil {
 		fmt.Printf("failed to read body: %v\n", err)
 		os.Exit(1)
 	}
+	if curlReq != nil && curlReq.hasBody && *data == "" {
+		bodyBytes = curlReq.body // already read, as curl reads @file
+	}
 
-	parsedURL, err := url.Parse(targetURL)
-	if err != nil {
-		fmt.Printf("invalid url: %v\n", err)
-		os.Exit(1)
+	var stages []stage
+	if *stagesFlag != "" {
+		if *rate > 0 || *rps > 0 {
+			fmt.Println("stages cannot be combined with q or rate")
+			os.Exit(1)
+		}
+		stages, err = parseStages(*stagesFlag)
+		if err != nil {
+			fmt.Printf("invalid stages: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *search {
+		if *rate > 0 || *rps > 0 || len(stages) > 0 {
+			fmt.Println("search cannot be combined with q, rate or stages")
+			os.Exit(1)
+		}
+		if *searchStepRate <= 0 || *searchTime <= 0 || *sloP99 <= 0 || *sloErrors <= 0 {
+			fmt.Println("search-step, search-time, slo-p99 and slo-errors must be > 0")
+			os.Exit(1)
+		}
 	}
-	if parsedURL.Scheme == "" {
-		fmt.Println("URL must include scheme")
+	if *replayPath != "" && *replaySpeed > 0 && (*rate > 0 || *rps > 0 || len(stages) > 0 || *search) {
+		fmt.Println("replay cannot be combined with q, rate, stages or search unless replay-speed is 0")
 		os.Exit(1)
 	}
-
-	// Build request template
-	reqTpl, err := http.NewRequestWithContext(context.Background(), strings.ToUpper(*method), parsedURL.String(), nil)
-	if err != nil {
-		fmt.Printf("request build: %v\n", err)
+	if *replaySpeed < 0 {
+		fmt.Println("replay-speed must be ≥ 0")
 		os.Exit(1)
 	}
-	if len(bodyBytes) > 0 {
-		reqTpl.Body = io.NopCloser(bytes.NewReader(bodyBytes))
-		reqTpl.GetBody = func() (io.ReadCloser, error) {
-			return io.NopCloser(bytes.NewReader(bodyBytes)), nil
+	if *harTiming {
+		if *harPath == "" {
+			fmt.Println("har-timing requires har")
+			os.Exit(1)
+		}
+		if *rate > 0 || *rps > 0 || len(stages) > 0 || *search {
+			fmt.Println("har-timing cannot be combined with q, rate, stages or search")
+			os.Exit(1)
+		}
+	}
+
+	// without -success, the default policy applies unless a status check
+	// replaces it, see defaultSuccess
+	var success statusPolicy
+	if explicit["success"] {
+		var err error
+		if success, err = parseStatusPolicy(*successFlag); err != nil {
+			fmt.Printf("invalid success: %v\n", err)
+			os.Exit(1)
 		}
 	}
 
 	// Headers
+	header := http.Header{}
 	for _, h := range headers {
 		parts := strings.SplitN(h, ":", 2)
 		if len(parts) != 2 {
 			fmt.Printf("invalid header %q, must be key:value\n", h)
 			os.Exit(1)
 		}
-		reqTpl.Header.Add(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
+		header.Add(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
+	}
+
+	// Build request templates, one per target
+	// Template variables
+	var rows *feed
+	if *feedPath != "" {
+		if *feedEnd != "wrap" && *feedEnd != "stop" {
+			fmt.Println("feed-end must be wrap or stop")
+			os.Exit(1)
+		}
+		if *feedEnd == "stop" && *feedMode != "sequential" {
+			fmt.Println("feed-end stop requires feed-mode sequential")
+			os.Exit(1)
+		}
+		rows, err = loadFeed(*feedPath, *feedMode)
+		if err != nil {
+			fmt.Printf("invalid feed: %v\n", err)
+			os.Exit(1)
+		}
+		if *feedEnd == "stop" {
+			*totalReq = min(*totalReq, len(rows.rows)) // one request per row
+		}
+	}
+
+	defaults := targetDefaults{method: *method, header: header, body: bodyBytes, template: *templating || rows != nil, checks: checks, success: success}
+	if rows != nil {
+		defaults.vars = rows.rows[0]
+	}
+	urls := flag.Args()
+	if curlReq != nil {
+		urls = append(urls, curlReq.url)
+	}
+	var targetList []*target
+	for _, arg := range urls {
+		t, err := newTarget(targetSpec{URL: arg}, defaults)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		targetList = append(targetList, t)
+	}
+	if *targetsPath != "" {
+		fromFile, err := loadTargets(*targetsPath, defaults)
+		if err != nil {
+			fmt.Printf("invalid targets: %v\n", err)
+			os.Exit(1)
+		}
+		targetList = append(targetList, fromFile...)
+	}
+	var (
+		replay    *harReplay
+		accessLog *logReplay
+		order     []int // of targets, when replaying recorded requests
+	)
+	if *harPath != "" {
+		if len(targetList) > 0 {
+			fmt.Println("har cannot be combined with urls, targets or curl")
+			os.Exit(1)
+		}
+		replay, err = loadHAR(*harPath, defaults)
+		if err != nil {
+			fmt.Printf("invalid har: %v\n", err)
+			os.Exit(1)
+		}
+		targetList = replay.targets
+		for i := range targetList {
+			order = append(order, i)
+		}
+	}
+	if *replayPath != "" {
+		if len(targetList) > 0 {
+			fmt.Println("replay cannot be combined with urls, targets, curl or har")
+			os.Exit(1)
+		}
+		accessLog, err = loadAccessLog(*replayPath, *replayBase, defaults)
+		if err != nil {
+			fmt.Printf("invalid replay: %v\n", err)
+			os.Exit(1)
+		}
+		targetList, order = accessLog.targets, accessLog.order
+		*totalReq = min(*totalReq, len(order)) // the log is replayed once
+	}
+	var sc *scenario
+	if *scenarioPath != "" {
+		if len(targetList) > 0 {
+			fmt.Println("scenario cannot be combined with urls, targets, curl, har or replay")
+			os.Exit(1)
+		}
+		sc, err = loadScenario(*scenarioPath, defaults)
+		if err != nil {
+			fmt.Printf("invalid scenario: %v\n", err)
+			os.Exit(1)
+		}
+		sc.feed = rows
+		targetList = sc.targets()
+	}
+	targets := newTargetSet(targetList)
+	targets.order = order
+	targets.feed = rows
+	targets.scenario = sc
+
+	// an arrival schedule, if any, drives the load rather than the workers
+	var schedule arrivals
+	switch {
+	case *rate > 0:
+		schedule = constantRate(*rate)
+	case len(stages) > 0:
+		schedule = stagedRate(stages)
+	case *harTiming:
+		schedule = timeline(replay.offsets, replay.cycle, 1)
+	case accessLog != nil && *replaySpeed > 0:
+		schedule = timeline(accessLog.offsets, accessLog.offsets[len(accessLog.offsets)-1], *replaySpeed)
+	}
+	if (schedule != nil || *search) && *maxConcur < *concur {
+		fmt.Println("max-c must be ≥ c")
+		os.Exit(1)
 	}
 
 	/* --- HTTP client configuration --- */
+	maxIdle := *concur
+	if schedule != nil || *search {
+		maxIdle = *maxConcur // the pool may grow up to this many workers
+	}
+	tlsConfig, err := newTLSConfig(tlsOptions{
+		insecure:   *insecure,
+		certFile:   *certFile,
+		keyFile:    *keyFile,
+		caFile:     *caFile,
+		minVersion: *tlsMin,
+		maxVersion: *tlsMax,
+		ciphers:    *ciphers,
+		serverName: *serverName,
+		resume:     *tlsResume,
+	})
+	if err != nil {
+		fmt.Printf("invalid TLS options: %v\n", err)
+		os.Exit(1)
+	}
 	tr := &http.Transport{
 		Proxy:               http.ProxyFromEnvironment,
-		MaxIdleConnsPerHost: *concur,
-		TLSClientConfig:     &tls.Config{InsecureSkipVerify: *insecure}, //nolint:gosec // User explicitly opted into insecure mode via -k flag
-		DisableCompression:  false,
-		ForceAttemptHTTP2:   *h2,
-		DisableKeepAlives:   *disableKeepAlives,
+		DialContext:         dialContext(resolves, connectTo),
+		MaxIdleConnsPerHost: maxIdle,
+		TLSClientConfig:     tlsConfig,
+		// like curl, an imported command only asks for compression with --compressed
+		DisableCompression: curlReq != nil && !curlReq.compressed,
+		ForceAttemptHTTP2:  *h2,
+		DisableKeepAlives:  *disableKeepAlives,
 	}
 	client := &http.Client{
 		Transport: tr,
@@ -110,60 +371,155 @@ func main() {
 			return http.ErrUseLastResponse
 		}
 	}
+	clientFor := workerClients(client, *cookieJar, *isolate)
 
 	// Channels & goroutines
-	jobCh := make(chan int, *concur)
+	// jobCh is unbuffered so that jobs are only handed to idle workers, and
+	// nothing is left queued when the run stops.
+	jobCh := make(chan job)
 	var wg sync.WaitGroup
-	results := &resultSet{start: time.Now()}
+	results := &resultSet{start: time.Now(), precision: *precision, paced: *rps > 0 || schedule != nil || *search, stages: stages}
+	if len(targetList) > 1 && accessLog == nil && replay == nil {
+		// an access log or HAR file has too many distinct requests to
+		// report on each, at the memory cost of their histograms
+		results.targets = targets.names()
+	}
 
 	// set up signal handling
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer cancel() // Ensure context is canceled when we exit
 
+	if *exportPath != "" {
+		format, err := exportFormat(*exportPath, *exportFmt)
+		if err != nil {
+			fmt.Printf("invalid export: %v\n", err)
+			os.Exit(1)
+		}
+		results.export, err = newRecordWriter(*exportPath, format, results.start)
+		if err != nil {
+			fmt.Printf("failed to create export file: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
 	if *live {
 		go startLiveMonitor(ctx, results)
 	}
 
-	var tickers []*time.Ticker
-	for i := range *concur {
+	workers := *concur
+	grow := func() {
+		if workers >= *maxConcur {
+			return
+		}
+		wg.Add(1)
+		go worker(ctx, workers, clientFor(workers), targets, jobCh, results, &wg, nil, *showTrace)
+		workers++
+	}
+	var steps []searchStep
+	target := slo{p99: *sloP99, errorRate: *sloErrors / 100}
+	// with a global arrival schedule the scheduler already spreads out
+	// requests, so workers need not be staggered
+	runWorkers(ctx, *concur, schedule == nil && !*search, *duration, func(i int) {
 		wg.Add(1)
 		var limiter <-chan time.Time
 		if *rps > 0 {
-			interval := time.Duration(float64(time.Second) / *rps)
-			t := time.NewTicker(interval)
-			tickers = append(tickers, t)
-			limiter = t.C
+			limiter = pace(ctx, time.Duration(float64(time.Second) / *rps))
 		}
-
-		// stagger starts with jitter to avoid synchronized bursts
-		base := time.Second / time.Duration(*concur)
-		jitter := time.Duration(rand.Int64N(int64(base/2 + 1))) //nolint:gosec // jitter doesn't need cryptographic randomness
-		time.Sleep(base + jitter)
-		go worker(ctx, i, client, reqTpl, jobCh, results, &wg, limiter, *showTrace)
-	}
-
-	// feed jobs
-	for i := 0; i < *totalReq; i++ {
-		select {
-		case jobCh <- i:
-			// Job sent successfully
-		case <-ctx.Done():
-			// Context was canceled, stop sending jobs
-			i = *totalReq // exit loop
+		go worker(ctx, i, clientFor(i), targets, jobCh, results, &wg, limiter, *showTrace)
+	}, func(feedCtx context.Context) {
+		switch {
+		case *search:
+			steps = searchCapacity(feedCtx, jobCh, *totalReq, *searchStepRate, *searchTime, target, results, grow)
+		case schedule != nil:
+			feedSchedule(feedCtx, jobCh, *totalReq, schedule, grow)
+		default:
+			feedJobs(feedCtx, jobCh, *totalReq)
 		}
-	}
+	})
 	close(jobCh)
 
 	// wait for jobs to finish
 	wg.Wait()
 
-	for _, t := range tickers {
-		t.Stop()
-	}
-
 	// collect results
 	results.end = time.Now()
-	results.summarize()
+	if results.export != nil {
+		if err := results.export.Close(); err != nil {
+			fmt.Printf("failed to write export file: %v\n", err)
+		}
+	}
+	rep := results.report()
+	rep.Config = &runConfig{
+		Concurrency:     *concur,
+		Duration:        duration.Seconds(),
+		WorkerRPS:       *rps,
+		Rate:            *rate,
+		Stages:          *stagesFlag,
+		Search:          *search,
+		Feed:            *feedPath,
+		HAR:             *harPath,
+		Replay:          *replayPath,
+		Scenario:        *scenarioPath,
+		Timeout:         timeout.Seconds(),
+		HTTP2:           *h2,
+		KeepAlive:       !*disableKeepAlives,
+		FollowRedirects: !*noRedirect,
+		Insecure:        *insecure,
+		ClientCert:      *certFile,
+		CACert:          *caFile,
+		TLSMinVersion:   *tlsMin,
+		TLSMaxVersion:   *tlsMax,
+		Ciphers:         *ciphers,
+		ServerName:      *serverName,
+		TLSResume:       *tlsResume,
+		CookieJar:       *cookieJar || *isolate,
+		Isolated:        *isolate,
+		Resolve:         ruleExprs(resolves),
+		ConnectTo:       ruleExprs(connectTo),
+	}
+	switch {
+	case len(targetList) == 1:
+		rep.Config.URL = targetList[0].req.URL.String()
+		rep.Config.Method = targetList[0].req.Method
+	case accessLog == nil:
+		rep.Config.Targets = targets.names()
+	}
+	if *totalReq != math.MaxInt-1 {
+		rep.Config.Requests = *totalReq
+	}
+	if schedule != nil || *search {
+		rep.Config.MaxConcurrency = *maxConcur
+	}
+	if *search {
+		rep.Search = newSearchReport(steps, target)
+	}
+	if baseline != nil {
+		rep.Comparison = compareRuns(*comparePath, baseline, rep, results.distribution(), *compareTolerance)
+	}
+	var thresholdErr error
+	if len(thresholds) > 0 {
+		rep.Thresholds, thresholdErr = evaluateThresholds(thresholds, rep)
+	}
+
+	if *output == "json" {
+		if err := rep.writeJSON(os.Stdout); err != nil {
+			fmt.Printf("failed to write summary: %v\n", err)
+			os.Exit(1)
+		}
+	} else {
+		rep.writeText(os.Stdout)
+	}
+	if *savePath != "" {
+		if err := saveRun(*savePath, rep, results.distribution()); err != nil {
+			fmt.Printf("failed to save results: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if thresholdErr != nil {
+		// distinct from the status of usage errors, so CI can tell them apart
+		fmt.Fprintln(os.Stderr, thresholdErr)
+		os.Exit(2)
+	}
 }
 
 // headerSlice is for parsing HTTP headers
@@ -193,146 +549,203 @@ func loadBody(bodyFlag string) ([]byte, error) {
 
 // record is the result of a single request
 type record struct {
-	latency time.Duration
+	latency time.Duration // service time, from send to the end of the body
+	wait    time.Duration // delay between the intended and actual send time
+	stage   int
+	target  int // index of the target the request was sent to
 	status  int
 	size    int64
 	failed  bool
 	errMsg  string
+	phases  [numPhases]time.Duration // zero for phases that did not happen
+
+	errCategory string    // see errorCategory
+	sent        time.Time // when the request was sent
+	worker      int
+	reused      bool     // sent on a reused connection
+	checks      []string // checks the response failed, see check
+	tls         tlsSession
+	partial     bool // more records of its job follow, see scenario.run
 }
 
-type resultSet struct {
-	mu         sync.Mutex
-	records    []record
-	start, end time.Time
+// stats aggregates records in fixed memory.
+type stats struct {
+	count         int
+	jobs          int // completed jobs, each ending with a record that is not partial
+	failed        int
+	bytes         int64      // of successful requests
+	latency       *histogram // service time of successful requests
+	response      *histogram // latency plus wait of successful requests
+	failedLatency *histogram // service time of failed requests that got a response
+	statusCount   map[int]int
+	errors        map[string]errorCount // by category
+	checks        map[string]int        // responses that failed each check
 }
 
-func (r *resultSet) add(rec record) {
-	r.mu.Lock()
-	r.records = append(r.records, rec)
-	r.mu.Unlock()
+func newStats(digits int) *stats {
+	return &stats{
+		latency:       newHistogram(digits),
+		response:      newHistogram(digits),
+		failedLatency: newHistogram(digits),
+		statusCount:   map[int]int{},
+		errors:        map[string]errorCount{},
+		checks:        map[string]int{},
+	}
 }
 
-func (r *resultSet) summarize() {
-	total := len(r.records)
-	if total == 0 {
-		fmt.Println("No records, something went wrong.")
-		return
+func (s *stats) add(rec record) {
+	s.count++
+	if !rec.partial {
+		s.jobs++
 	}
-
-	latencies := make([]time.Duration, 0, total)
-	var bytesTotal int64
-	var failed int
-	statusCount := map[int]int{}
-
-	for _, rec := range r.records {
-		if rec.failed {
-			failed++
-			statusCount[rec.status]++
-			continue
-		}
-		latencies = append(latencies, rec.latency)
-		bytesTotal += rec.size
-		statusCount[rec.status]++
+	s.statusCount[rec.status]++
+	for _, c := range rec.checks {
+		s.checks[c]++
 	}
-
-	slices.SortFunc(latencies, cmp.Compare)
-
-	successful := len(latencies)
-	if successful == 0 {
-		fmt.Println("All requests failed, cannot provide summary.")
+	if rec.failed {
+		s.failed++
+		if rec.status != 0 {
+			s.failedLatency.record(rec.latency)
+		}
+		if rec.errMsg != "" {
+			e := s.errors[rec.errCategory]
+			if e.Count == 0 {
+				e.Example = rec.errMsg
+			}
+			e.Count++
+			s.errors[rec.errCategory] = e
+		}
 		return
 	}
+	s.bytes += rec.size
+	s.latency.record(rec.latency)
+	s.response.record(rec.wait + rec.latency)
+}
 
-	// Calculate basic stats
-	totalDur := r.end.Sub(r.start)
-	reqPerSec := float64(total) / totalDur.Seconds()
+func (s *stats) clone() *stats {
+	c := *s
+	c.latency = s.latency.clone()
+	c.response = s.response.clone()
+	c.failedLatency = s.failedLatency.clone()
+	c.statusCount = maps.Clone(s.statusCount)
+	c.errors = maps.Clone(s.errors)
+	c.checks = maps.Clone(s.checks)
+	return &c
+}
 
-	// Find minLatency, maxLatency, mean
-	minLatency := latencies[0]
-	maxLatency := latencies[successful-1]
+type resultSet struct {
+	mu         sync.Mutex
+	precision  int // significant digits of latency histograms, default 3
+	total      *stats
+	phases     [numPhases]*histogram // of successful requests
+	tls        *tlsStats             // handshakes of successful requests
+	byStage    []*stats              // indexed by record.stage
+	byTarget   []*stats              // indexed by record.target
+	start, end time.Time
+	paced      bool     // requests follow a rate schedule, see record.wait
+	stages     []stage  // load profile, if any, see record.stage
+	targets    []string // names of the targets, if more than one, see record.target
+	export     *recordWriter
+}
+
+// recorder receives the record of each completed request.
+type recorder interface {
+	add(rec record)
+}
 
-	mean := time.Duration(0)
-	for _, l := range latencies {
-		mean += l
+// digits returns the precision of the result set's histograms.
+func (r *resultSet) digits() int {
+	if r.precision == 0 {
+		return 3
 	}
-	mean /= time.Duration(successful)
+	return r.precision
+}
 
-	// Helper function for percentiles
-	pct := func(p float64) time.Duration {
-		if len(latencies) == 0 {
-			return 0
+func (r *resultSet) add(rec record) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.total == nil {
+		r.total = newStats(r.digits())
+		r.tls = newTLSStats(r.digits())
+		for i := range r.phases {
+			r.phases[i] = newHistogram(r.digits())
 		}
-		idx := int(float64(len(latencies))*p + .5)
-		if idx >= len(latencies) {
-			idx = len(latencies) - 1
+	}
+	r.total.add(rec)
+	r.tls.add(rec)
+	if r.export != nil {
+		r.export.write(rec)
+	}
+	for i, d := range rec.phases {
+		if d > 0 && !rec.failed {
+			r.phases[i].record(d)
 		}
-		return latencies[idx]
 	}
-
-	// Calculate histogram bins
-	histoBins := 11
-	binSize := (maxLatency - minLatency) / time.Duration(histoBins-1)
-	if binSize == 0 {
-		binSize = 1 * time.Millisecond // prevent division by zero
+	if len(r.targets) > 0 {
+		r.byTarget = r.addTo(r.byTarget, rec.target, rec)
+	}
+	if len(r.stages) > 0 {
+		// without a load profile every record is in stage 0
+		r.byStage = r.addTo(r.byStage, rec.stage, rec)
 	}
+}
 
-	bins := make([]int, histoBins)
-	for _, lat := range latencies {
-		binIdx := int((lat - minLatency) / binSize)
-		if binIdx >= histoBins {
-			binIdx = histoBins - 1
-		}
-		bins[binIdx]++
+// addTo adds rec to the idx-th stats of s, growing s as needed.
+func (r *resultSet) addTo(s []*stats, idx int, rec record) []*stats {
+	for len(s) <= idx {
+		s = append(s, nil)
+	}
+	if s[idx] == nil {
+		s[idx] = newStats(r.digits())
 	}
+	s[idx].add(rec)
+	return s
+}
 
-	// Find the max count for scaling histogram bars
-	maxCount := 0
-	for _, count := range bins {
-		if count > maxCount {
-			maxCount = count
-		}
+// distribution returns a copy of the latency distribution a run is judged
+// on: response time if paced, otherwise service time.
+func (r *resultSet) distribution() *histogram {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.total == nil {
+		return nil
 	}
+	if r.paced {
+		return r.total.response.clone()
+	}
+	return r.total.latency.clone()
+}
 
-	// Print summary
-	fmt.Printf("\nSummary:\n")
-	fmt.Printf("  Total:        %.4f secs\n", totalDur.Seconds())
-	fmt.Printf("  Slowest:      %.4f secs\n", maxLatency.Seconds())
-	fmt.Printf("  Fastest:      %.4f secs\n", minLatency.Seconds())
-	fmt.Printf("  Average:      %.4f secs\n", mean.Seconds())
-	fmt.Printf("  Requests/sec: %.4f\n", reqPerSec)
-	fmt.Printf("\n")
-	fmt.Printf("  Total data:   %d bytes\n", bytesTotal)
-	fmt.Printf("  Size/request: %d bytes\n", bytesTotal/int64(successful))
+// stage returns a snapshot of the stats of stage, or nil if it has no records.
+func (r *resultSet) stage(idx int) *stats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if idx >= len(r.byStage) || r.byStage[idx] == nil {
+		return nil
+	}
+	return r.byStage[idx].clone()
+}
 
-	// Print histogram
-	fmt.Printf("\nResponse time histogram:\n")
-	for i := range histoBins {
-		binTime := minLatency + time.Duration(i)*binSize
-		bar := ""
-		if maxCount > 0 {
-			barLength := int(40 * float64(bins[i]) / float64(maxCount))
-			bar = strings.Repeat("■", barLength)
+// waitStage waits until n jobs of stage have completed, or ctx is done.
+func (r *resultSet) waitStage(ctx context.Context, stage, n int) {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		r.mu.Lock()
+		done := 0
+		if stage < len(r.byStage) && r.byStage[stage] != nil {
+			done = r.byStage[stage].jobs
+		}
+		r.mu.Unlock()
+		if done >= n {
+			return
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
 		}
-		fmt.Printf("  %.3f [%d]\t|%s\n", binTime.Seconds(), bins[i], bar)
 	}
-	fmt.Printf("\n\n")
-
-	// Print latency distribution
-	fmt.Printf("Latency distribution:\n")
-	fmt.Printf("  10%% in %.4f secs\n", pct(0.10).Seconds())
-	fmt.Printf("  25%% in %.4f secs\n", pct(0.25).Seconds())
-	fmt.Printf("  50%% in %.4f secs\n", pct(0.50).Seconds())
-	fmt.Printf("  75%% in %.4f secs\n", pct(0.75).Seconds())
-	fmt.Printf("  90%% in %.4f secs\n", pct(0.90).Seconds())
-	fmt.Printf("  95%% in %.4f secs\n", pct(0.95).Seconds())
-	fmt.Printf("  99%% in %.4f secs\n", pct(0.99).Seconds())
-
-	// Note: Detailed timing metrics would require additional instrumentation
-	fmt.Printf("\nDetails (average, fastest, slowest):\n")
-	fmt.Printf("  resp wait:    %.4f secs, %.4f secs, %.4f secs\n", mean.Seconds(), minLatency.Seconds(), maxLatency.Seconds())
-
-	// Print status code distribution
-	fmt.Print(statusCodeDistribution(statusCount))
 }
 
 func statusCodeDistribution(statusCount map[int]int) string {
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// check is a condition on each response. A response that fails a check is
+// counted as a failed request, like a transport error.
+type check struct {
+	expr     string // as given
+	kind     string // status, contains, regex, json, max-size or header
+	statuses statusPolicy
+	text     string // contains: the text; json and header: the wanted value
+	hasValue bool   // json and header: whether a value is wanted, or only presence
+	re       *regexp.Regexp
+	path     []pathElem
+	name     string // header: its name
+	size     int64
+}
+
+// checkKinds are the kinds of check, as in <kind>:<argument>.
+var checkKinds = []string{"status", "contains", "regex", "json", "max-size", "header"}
+
+// checkError is a response that failed a check.
+type checkError struct {
+	check string
+	err   error
+}
+
+func (e *checkError) Error() string { return fmt.Sprintf("check %s: %v", e.check, e.err) }
+func (e *checkError) Unwrap() error { return e.err }
+
+// statusPolicy is the status codes and classes of successful responses,
+// e.g. 2xx,3xx. Responses with any other status are counted as failed. An
+// empty policy accepts every status.
+type statusPolicy []string
+
+// parseStatusPolicy parses a comma-separated list of status codes and
+// classes.
+func parseStatusPolicy(s string) (statusPolicy, error) {
+	var p statusPolicy
+	for v := range strings.SplitSeq(s, ",") {
+		v = strings.TrimSpace(v)
+		if !statusRE.MatchString(v) {
+			return nil, fmt.Errorf("%q is not a status code or class", v)
+		}
+		p = append(p, v)
+	}
+	return p, nil
+}
+
+// accepts reports whether a response with status code is successful.
+func (p statusPolicy) accepts(code int) bool {
+	return len(p) == 0 || slices.ContainsFunc(p, func(s string) bool { return statusMatches(code, s) })
+}
+
+// defaultSuccess is the status policy without -success. A status check
+// replaces it, so that e.g. -check status:404 expects 404s.
+var defaultSuccess = statusPolicy{"2xx", "3xx"}
+
+// statusError is a response whose status is not successful.
+type statusError struct {
+	code int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unsuccessful status %d %s", e.code, http.StatusText(e.code))
+}
+
+// checkSlice is for parsing repeatable -check flags
+type checkSlice []*check
+
+func (c *checkSlice) String() string {
+	exprs := make([]string, len(*c))
+	for i, ch := range *c {
+		exprs[i] = ch.expr
+	}
+	return strings.Join(exprs, " ")
+}
+
+func (c *checkSlice) Set(v string) error {
+	ch, err := parseCheck(v)
+	if err != nil {
+		return err
+	}
+	*c = append(*c, ch)
+	return nil
+}
+
+// parseCheck parses a check of the form <kind>:<argument>:
+//
+//	status:200,201,3xx   the status is one of these codes or classes
+//	contains:TEXT        the body contains TEXT
+//	regex:RE             the body matches RE
+//	json:PATH=VALUE      the JSON body has VALUE at PATH, or any value but null without =VALUE
+//	max-size:SIZE        the body is at most SIZE bytes, or with a KB, MB or GB suffix
+//	header:NAME=VALUE    the response has header NAME, with VALUE if given
+func parseCheck(expr string) (*check, error) {
+	kind, arg, ok := strings.Cut(expr, ":")
+	if !ok || !slices.Contains(checkKinds, kind) {
+		return nil, fmt.Errorf("invalid check %q, must be <kind>:<argument> with kind one of %s", expr, strings.Join(checkKinds, ", "))
+	}
+	if arg == "" {
+		return nil, fmt.Errorf("invalid check %q: missing argument", expr)
+	}
+
+	c := &check{expr: expr, kind: kind}
+	var err error
+	switch kind {
+	case "status":
+		c.statuses, err = parseStatusPolicy(arg)
+	case "contains":
+		c.text = arg
+	case "regex":
+		c.re, err = regexp.Compile(arg)
+	case "json":
+		path, value, hasValue := strings.Cut(arg, "=")
+		c.text, c.hasValue = value, hasValue
+		c.path, err = parseJSONPath(path)
+	case "max-size":
+		c.size, err = parseSize(arg)
+	case "header":
+		c.name, c.text, c.hasValue = strings.Cut(arg, "=")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid check %q: %w", expr, err)
+	}
+	return c, nil
+}
+
+// parseSize parses a number of bytes, optionally with a KB, MB or GB suffix,
+// in units of 1024.
+func parseSize(s string) (int64, error) {
+	n, unit := strings.ToUpper(strings.TrimSpace(s)), int64(1)
+	for i, suffix := range []string{"KB", "MB", "GB"} {
+		if num, ok := strings.CutSuffix(n, suffix); ok {
+			n, unit = strings.TrimSpace(num), 1<<(10*(i+1))
+			break
+		}
+	}
+	v, err := strconv.ParseInt(strings.TrimSuffix(n, "B"), 10, 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return v * unit, nil
+}
+
+// needsBody reports whether c reads the response body.
+func (c *check) needsBody() bool {
+	return c.kind == "contains" || c.kind == "regex" || c.kind == "json"
+}
+
+// verify checks a response with the given body, of size bytes.
+func (c *check) verify(resp *http.Response, body []byte, size int64) error {
+	var err error
+	switch c.kind {
+	case "status":
+		if !c.statuses.accepts(resp.StatusCode) {
+			err = fmt.Errorf("got status %d", resp.StatusCode)
+		}
+	case "contains":
+		if !bytes.Contains(body, []byte(c.text)) {
+			err = errors.New("body does not contain the text")
+		}
+	case "regex":
+		if !c.re.Match(body) {
+			err = errors.New("body does not match")
+		}
+	case "json":
+		doc, jsonErr := decodeJSON(body)
+		if jsonErr != nil {
+			err = fmt.Errorf("body is not JSON: %w", jsonErr)
+			break
+		}
+		v, pathErr := lookupJSONPath(doc, c.path)
+		switch {
+		case pathErr != nil:
+			err = pathErr
+		case c.hasValue && (v == nil || jsonString(v) != c.text):
+			err = fmt.Errorf("got %s", jsonString(v))
+		case !c.hasValue && v == nil:
+			err = errors.New("value is null")
+		}
+	case "max-size":
+		if size > c.size {
+			err = fmt.Errorf("body is %d bytes", size)
+		}
+	case "header":
+		values := resp.Header.Values(c.name)
+		switch {
+		case len(values) == 0:
+			err = errors.New("header is missing")
+		case c.hasValue && !slices.Contains(values, c.text):
+			err = fmt.Errorf("got %s", strings.Join(values, ", "))
+		}
+	}
+	if err != nil {
+		return &checkError{check: c.expr, err: err}
+	}
+	return nil
+}
+
+// verify checks the response of rec from t, unless the request already
+// failed. The response fails if its status is not successful, or if it fails
+// any of t's checks; all the checks it fails are counted, and the first is its
+// error. Without a -success policy, successful statuses are those of
+// defaultSuccess, unless t has a status check to decide.
+func (rec *record) verify(t *target, resp *http.Response, body []byte) {
+	if rec.failed {
+		return
+	}
+	success := t.success
+	if success == nil && !slices.ContainsFunc(t.checks, func(c *check) bool { return c.kind == "status" }) {
+		success = defaultSuccess
+	}
+	if !success.accepts(resp.StatusCode) {
+		rec.fail(&statusError{code: resp.StatusCode})
+		return // an error page would fail checks on the body too
+	}
+	var first error
+	for _, c := range t.checks {
+		if err := c.verify(resp, body, rec.size); err != nil {
+			rec.checks = append(rec.checks, c.expr)
+			if first == nil {
+				first = err
+			}
+		}
+	}
+	if first != nil {
+		rec.fail(first)
+	}
+}
+
+// checksNeedBody reports whether any of checks reads the response body.
+func checksNeedBody(checks []*check) bool {
+	return slices.ContainsFunc(checks, (*check).needsBody)
+}
+
+// checkDistribution formats the number of responses that failed each check,
+// most frequent first.
+func checkDistribution(failures map[string]int) string {
+	keys := make([]string, 0, len(failures))
+	for k := range failures {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, func(a, b string) int {
+		if failures[a] != failures[b] {
+			return failures[b] - failures[a]
+		}
+		return strings.Compare(a, b)
+	})
+
+	var sb strings.Builder
+	sb.WriteString("\nCheck failures:\n")
+	for _, k := range keys {
+		fmt.Fprintf(&sb, "  [%d] %s\n", failures[k], k)
+	}
+	return sb.String()
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseCheck(t *testing.T) {
+	for _, expr := range []string{
+		"status:200", "status:200, 3xx", "contains:Welcome", "regex:id=\\d+",
+		"json:$.ok=true", "json:$.items[0]", "max-size:512",
+		"header:X-Id", "header:Content-Type=application/json",
+	} {
+		c, err := parseCheck(expr)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", expr, err)
+			continue
+		}
+		if c.expr != expr {
+			t.Errorf("%s: expected expr to be kept, got %s", expr, c.expr)
+		}
+	}
+
+	for _, expr := range []string{"", "status", "status:", "status:600", "status:20x", "body:x", "regex:(", "json:$[x]", "max-size:-1", "max-size:1.5KB", "max-size:lots"} {
+		if _, err := parseCheck(expr); err == nil {
+			t.Errorf("%q: expected an error", expr)
+		}
+	}
+
+	for s, want := range map[string]int64{"512": 512, "512B": 512, "2KB": 2048, "1 mb": 1 << 20, "1GB": 1 << 30} {
+		got, err := parseSize(s)
+		if err != nil || got != want {
+			t.Errorf("%s: expected %d, got %d (%v)", s, want, got, err)
+		}
+	}
+}
+
+func TestStatusPolicy(t *testing.T) {
+	p, err := parseStatusPolicy("2xx, 404")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for code, want := range map[int]bool{200: true, 204: true, 301: false, 404: true, 429: false, 503: false} {
+		if got := p.accepts(code); got != want {
+			t.Errorf("%d: expected %v, got %v", code, want, got)
+		}
+	}
+	if !statusPolicy(nil).accepts(500) {
+		t.Error("expected an empty policy to accept every status")
+	}
+	for _, s := range []string{"", "2xx,", "ok", "600"} {
+		if _, err := parseStatusPolicy(s); err == nil {
+			t.Errorf("%q: expected an error", s)
+		}
+	}
+}
+
+func TestCheckVerify(t *testing.T) {
+	resp := &http.Response{StatusCode: 200, Header: http.Header{"Content-Type": {"application/json"}}}
+	body := []byte(`{"ok": true, "user": {"id": 42, "name": null, "account": 12345678901234567890}}`)
+
+	tests := []struct {
+		expr string
+		pass bool
+	}{
+		{"status:200", true},
+		{"status:2xx", true},
+		{"status:201,3xx", false},
+		{"contains:\"ok\"", true},
+		{"contains:error", false},
+		{"regex:\"id\":\\s*\\d+", true},
+		{"regex:^<html", false},
+		{"json:$.ok=true", true},
+		{"json:$.user.id=42", true},
+		{"json:$.user.id=43", false},
+		{"json:$.user.id", true},
+		{"json:$.user.name", false},
+		{"json:$.user.email", false},
+		{"json:$.user.account=12345678901234567890", true},
+		{"json:$.user.account=12345678901234567000", false},
+		{"max-size:1KB", true},
+		{"max-size:10", false},
+		{"header:content-type", true},
+		{"header:Content-Type=application/json", true},
+		{"header:Content-Type=text/html", false},
+		{"header:X-Request-Id", false},
+	}
+	for _, tt := range tests {
+		c, err := parseCheck(tt.expr)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.expr, err)
+		}
+		err = c.verify(resp, body, int64(len(body)))
+		if (err == nil) != tt.pass {
+			t.Errorf("%s: expected pass %v, got %v", tt.expr, tt.pass, err)
+		}
+		if err != nil && errorCategory(err) != "check failed" {
+			t.Errorf("%s: expected category check failed, got %s", tt.expr, errorCategory(err))
+		}
+	}
+
+	html, err := parseCheck("json:$.ok")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := html.verify(resp, []byte("<html>"), 6); err == nil {
+		t.Error("expected a body that is not JSON to fail a json check")
+	}
+}
+
+func TestRecordVerify(t *testing.T) {
+	var checks checkSlice
+	for _, expr := range []string{"status:2xx", "contains:Welcome", "max-size:1"} {
+		if err := checks.Set(expr); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	resp := &http.Response{StatusCode: 200, Header: http.Header{}}
+
+	t200 := &target{checks: checks, success: statusPolicy{"2xx"}}
+	rec := record{status: 200, size: 12}
+	rec.verify(t200, resp, []byte("Error page!!"))
+	if !rec.failed || rec.errCategory != "check failed" {
+		t.Errorf("expected the response to fail its checks, got %+v", rec)
+	}
+	if want := []string{"contains:Welcome", "max-size:1"}; !slices.Equal(rec.checks, want) {
+		t.Errorf("expected failed checks %v, got %v", want, rec.checks)
+	}
+
+	// an unsuccessful status fails the response without checking its body
+	rec = record{status: 503, size: 12}
+	rec.verify(t200, &http.Response{StatusCode: 503}, []byte("Error page!!"))
+	if !rec.failed || rec.errCategory != "5xx status" || len(rec.checks) != 0 {
+		t.Errorf("expected the response to fail by its status, got %+v", rec)
+	}
+
+	// a status check replaces the default policy, but not an explicit one
+	notFound, err := parseCheck("status:404")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp404 := &http.Response{StatusCode: 404, Header: http.Header{}}
+	for _, tc := range []struct {
+		target *target
+		failed bool
+	}{
+		{&target{checks: []*check{notFound}}, false},
+		{&target{}, true},
+		{&target{checks: []*check{notFound}, success: statusPolicy{"2xx"}}, true},
+	} {
+		rec = record{status: 404}
+		rec.verify(tc.target, resp404, nil)
+		if rec.failed != tc.failed {
+			t.Errorf("checks %v with success %v: expected failed %v for a 404, got %+v", tc.target.checks, tc.target.success, tc.failed, rec)
+		}
+	}
+
+	rec = record{failed: true, errMsg: "dummy error"}
+	rec.verify(t200, nil, nil)
+	if len(rec.checks) != 0 || rec.errMsg != "dummy error" {
+		t.Errorf("expected a failed request not to be checked, got %+v", rec)
+	}
+
+	results := &resultSet{start: time.Now()}
+	results.add(record{status: 200, size: 12, failed: true, errMsg: "check contains:Welcome: ...", errCategory: "check failed", checks: []string{"contains:Welcome", "max-size:1"}})
+	results.add(record{status: 200, size: 12, failed: true, errMsg: "check contains:Welcome: ...", errCategory: "check failed", checks: []string{"contains:Welcome"}})
+	results.add(record{status: 200, latency: time.Millisecond})
+	results.end = results.start.Add(time.Second)
+	rep := results.report()
+	if rep.Failed != 2 || rep.CheckFailures["contains:Welcome"] != 2 || rep.CheckFailures["max-size:1"] != 1 {
+		t.Errorf("expected 2 failed and check failures of 2 and 1, got %d and %v", rep.Failed, rep.CheckFailures)
+	}
+}
+
+func TestWorkerChecks(t *testing.T) {
+	client := &http.Client{Transport: dummyRoundTripper{}, Timeout: 5 * time.Second}
+	welcome, err := parseCheck("contains:Welcome")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tgt, err := newTarget(targetSpec{URL: "http://example.com"}, targetDefaults{method: "GET", checks: []*check{welcome}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	jobCh := make(chan job, 1)
+	jobCh <- job{}
+	close(jobCh)
+	results := &recordLog{}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	worker(t.Context(), 0, client, newTargetSet([]*target{tgt}), jobCh, results, &wg, nil, false)
+
+	if len(results.records) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(results.records))
+	}
+	if rec := results.records[0]; !rec.failed || rec.status != 200 || rec.size != 2 || !slices.Equal(rec.checks, []string{"contains:Welcome"}) {
+		t.Errorf("expected a 200 response of 2 bytes to fail its check, got %+v", rec)
+	}
+}
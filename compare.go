@@ -0,0 +1,267 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// zCritical is the two-sided critical value of the standard normal
+// distribution at 95% confidence.
+const zCritical = 1.96
+
+// minSamples is the fewest requests either run needs for a change to be
+// tested for significance; the tests rely on normal approximations.
+const minSamples = 30
+
+// savedRun is the persisted result of a run: its report, plus the full
+// latency distribution so that a later run can test percentiles against it.
+type savedRun struct {
+	*report
+	Distribution *histogramSnapshot `json:"distribution,omitempty"` // response time if paced, else service time
+}
+
+// saveRun writes the report and latency distribution of a run to path.
+func saveRun(path string, rep *report, dist *histogram) error {
+	run := savedRun{report: rep}
+	if dist != nil {
+		run.Distribution = dist.snapshot()
+	}
+	data, err := json.MarshalIndent(run, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644) //nolint:gosec // results are not secret
+}
+
+// loadRun reads a run saved by saveRun. The output of -o json can also be
+// loaded, but without a distribution percentiles are not tested for
+// significance.
+func loadRun(path string) (*savedRun, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // User explicitly specified file path via -compare flag
+	if err != nil {
+		return nil, err
+	}
+	run := &savedRun{report: &report{}}
+	if err := json.Unmarshal(data, run); err != nil {
+		return nil, err
+	}
+	if run.Requests == 0 {
+		return nil, fmt.Errorf("%s holds no results", path)
+	}
+	return run, nil
+}
+
+// comparisonRow compares a metric of two runs.
+type comparisonRow struct {
+	Metric      string   `json:"metric"`
+	Baseline    float64  `json:"baseline"`
+	Current     float64  `json:"current"`
+	Change      *float64 `json:"change_pct,omitempty"` // relative change, if the baseline is non-zero
+	Significant bool     `json:"significant"`
+	Regression  bool     `json:"regression"`
+
+	unit string // "secs", "%" or "" for a rate
+}
+
+// comparison is the difference between a run and a baseline.
+type comparison struct {
+	Baseline    string          `json:"baseline"`
+	Tolerance   float64         `json:"tolerance_pct"`
+	Rows        []comparisonRow `json:"rows"`
+	Regressions int             `json:"regressions"`
+}
+
+// compareRuns compares the report of a run, and its latency distribution if
+// known, against a baseline. Latency and throughput only count as regressions
+// when they are worse by more than tolerance percent, so that negligible but
+// statistically significant changes in long runs are not flagged, and latency
+// must also be significantly worse if both distributions are known. Status
+// code and error shares count whenever they are significantly worse.
+func compareRuns(name string, base *savedRun, cur *report, curDist *histogram, tolerance float64) *comparison {
+	c := &comparison{Baseline: name, Tolerance: tolerance}
+
+	baseDist := restore(base.Distribution)
+	baseLat, curLat := effectiveLatency(base.report), effectiveLatency(cur)
+	if baseLat != nil && curLat != nil {
+		// without both distributions, latency is judged on tolerance alone
+		tested := baseDist != nil && curDist != nil && enough(baseDist.count(), curDist.count())
+		slower := func(row comparisonRow) bool {
+			return row.Current > row.Baseline && exceeds(row.Change, tolerance) && (row.Significant || !tested)
+		}
+
+		// the mean is tested with a two-sample z-test
+		mean := newComparisonRow("mean", baseLat.Mean, curLat.Mean, "secs")
+		if tested {
+			sb, sc := baseDist.stddev().Seconds(), curDist.stddev().Seconds()
+			se := math.Sqrt(sb*sb/float64(baseDist.count()) + sc*sc/float64(curDist.count()))
+			mean.Significant = se > 0 && math.Abs(curLat.Mean-baseLat.Mean)/se > zCritical
+		}
+		c.add(mean, slower(mean))
+
+		// percentiles are tested by whether their confidence intervals overlap
+		for _, p := range []struct {
+			name      string
+			q         float64
+			base, cur float64
+		}{
+			{"p50", 0.50, baseLat.P50, curLat.P50},
+			{"p90", 0.90, baseLat.P90, curLat.P90},
+			{"p95", 0.95, baseLat.P95, curLat.P95},
+			{"p99", 0.99, baseLat.P99, curLat.P99},
+		} {
+			row := newComparisonRow(p.name, p.base, p.cur, "secs")
+			if tested {
+				baseLo, baseHi := quantileInterval(baseDist, p.q)
+				curLo, curHi := quantileInterval(curDist, p.q)
+				row.Significant = curLo > baseHi || curHi < baseLo
+			}
+			c.add(row, slower(row))
+		}
+		c.add(newComparisonRow("max", baseLat.Max, curLat.Max, "secs"), false)
+	}
+
+	// throughput has no variance to test against, so it is judged on tolerance alone
+	rps := newComparisonRow("requests/sec", base.RequestsPerSec, cur.RequestsPerSec, "")
+	c.add(rps, rps.Current < rps.Baseline && exceeds(rps.Change, tolerance))
+
+	errRate := shareRow("error rate", base.Failed, base.Requests, cur.Failed, cur.Requests)
+	c.add(errRate, errRate.Significant && errRate.Current > errRate.Baseline)
+
+	codes := keys(base.StatusCodes, cur.StatusCodes)
+	slices.Sort(codes)
+	for _, code := range codes {
+		row := shareRow("status "+strconv.Itoa(code), base.StatusCodes[code], base.Requests, cur.StatusCodes[code], cur.Requests)
+		// a smaller share of successes is as bad as a larger share of failures
+		worse := row.Current > row.Baseline
+		if code >= 200 && code < 400 {
+			worse = row.Current < row.Baseline
+		}
+		c.add(row, row.Significant && worse)
+	}
+
+	categories := keys(base.Errors, cur.Errors)
+	slices.Sort(categories)
+	for _, cat := range categories {
+		row := shareRow("error "+cat, base.Errors[cat].Count, base.Requests, cur.Errors[cat].Count, cur.Requests)
+		c.add(row, row.Significant && row.Current > row.Baseline)
+	}
+	return c
+}
+
+func (c *comparison) add(row comparisonRow, regression bool) {
+	row.Regression = regression
+	if regression {
+		c.Regressions++
+	}
+	c.Rows = append(c.Rows, row)
+}
+
+func newComparisonRow(metric string, base, cur float64, unit string) comparisonRow {
+	row := comparisonRow{Metric: metric, Baseline: base, Current: cur, unit: unit}
+	if base != 0 {
+		change := 100 * (cur - base) / base
+		row.Change = &change
+	}
+	return row
+}
+
+// shareRow compares the percentage of requests counted by n in each run, with
+// a two-proportion z-test.
+func shareRow(metric string, baseN, baseTotal, curN, curTotal int) comparisonRow {
+	pb, pc := float64(baseN)/float64(baseTotal), float64(curN)/float64(curTotal)
+	row := newComparisonRow(metric, 100*pb, 100*pc, "%")
+	if enough(int64(baseTotal), int64(curTotal)) {
+		pooled := float64(baseN+curN) / float64(baseTotal+curTotal)
+		se := math.Sqrt(pooled * (1 - pooled) * (1/float64(baseTotal) + 1/float64(curTotal)))
+		row.Significant = se > 0 && math.Abs(pc-pb)/se > zCritical
+	}
+	return row
+}
+
+// quantileInterval returns a distribution-free 95% confidence interval of
+// the q-th quantile, in seconds, from the ranks that bound it.
+func quantileInterval(h *histogram, q float64) (lo, hi float64) {
+	n := float64(h.count())
+	spread := zCritical * math.Sqrt(n*q*(1-q)) / n
+	return h.percentile(max(q-spread, 0)).Seconds(), h.percentile(min(q+spread, 1)).Seconds()
+}
+
+// effectiveLatency returns the latency a run is judged on: response time if
+// the run was paced, since service time hides queuing delay.
+func effectiveLatency(rep *report) *latencyReport {
+	if rep.ResponseTime != nil {
+		return rep.ResponseTime
+	}
+	return rep.Latency
+}
+
+func restore(s *histogramSnapshot) *histogram {
+	if s == nil {
+		return nil
+	}
+	h, err := s.histogram()
+	if err != nil {
+		return nil
+	}
+	return h
+}
+
+func enough(a, b int64) bool { return a >= minSamples && b >= minSamples }
+
+func exceeds(change *float64, tolerance float64) bool {
+	return change == nil || math.Abs(*change) > tolerance
+}
+
+func keys[K comparable, V any](a, b map[K]V) []K {
+	var ks []K
+	for k := range a {
+		ks = append(ks, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			ks = append(ks, k)
+		}
+	}
+	return ks
+}
+
+// comparisonTable formats a comparison side by side.
+func comparisonTable(c *comparison) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "\nComparison with %s:\n", c.Baseline)
+	fmt.Fprintf(&sb, "  %-28s %14s %14s %9s\n", "", "baseline", "current", "change")
+	for _, row := range c.Rows {
+		change := "n/a"
+		if row.Change != nil {
+			change = fmt.Sprintf("%+.1f%%", *row.Change)
+		}
+		flag := ""
+		if row.Significant {
+			flag = " *"
+		}
+		if row.Regression {
+			flag += " REGRESSION"
+		}
+		fmt.Fprintf(&sb, "  %-28s %14s %14s %9s%s\n", row.Metric,
+			formatValue(row.Baseline, row.unit), formatValue(row.Current, row.unit), change, flag)
+	}
+	fmt.Fprintf(&sb, "  * statistically significant at 95%% confidence\n")
+	fmt.Fprintf(&sb, "  %d regressions (tolerance %.1f%%)\n", c.Regressions, c.Tolerance)
+	return sb.String()
+}
+
+func formatValue(v float64, unit string) string {
+	switch unit {
+	case "secs":
+		return fmt.Sprintf("%.4f secs", v)
+	case "%":
+		return fmt.Sprintf("%.2f%%", v)
+	default:
+		return fmt.Sprintf("%.4f", v)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// comparisonRun returns the report and distribution of n requests of the
+// given latency, failed of which fail, over a second.
+func comparisonRun(n, failed int, latency time.Duration) (*report, *histogram) {
+	rs := &resultSet{start: time.Now().Add(-time.Second), end: time.Now()}
+	for i := range n {
+		if i < failed {
+			rs.add(record{failed: true, errMsg: "timeout", errCategory: "timeout"})
+			continue
+		}
+		// spread latencies by ±10% so the distributions have some variance
+		jitter := time.Duration(i%21-10) * latency / 100
+		rs.add(record{latency: latency + jitter, status: 200})
+	}
+	return rs.report(), rs.distribution()
+}
+
+func findRow(t *testing.T, c *comparison, metric string) comparisonRow {
+	t.Helper()
+	for _, row := range c.Rows {
+		if row.Metric == metric {
+			return row
+		}
+	}
+	t.Fatalf("missing row %s", metric)
+	return comparisonRow{}
+}
+
+func TestCompareRunsRegression(t *testing.T) {
+	baseRep, baseDist := comparisonRun(1000, 0, 100*time.Millisecond)
+	base := &savedRun{report: baseRep, Distribution: baseDist.snapshot()}
+	rep, dist := comparisonRun(1000, 50, 150*time.Millisecond)
+
+	c := compareRuns("base.json", base, rep, dist, 5)
+	for _, metric := range []string{"mean", "p50", "p99", "error rate", "status 200", "error timeout"} {
+		row := findRow(t, c, metric)
+		if !row.Significant || !row.Regression {
+			t.Errorf("%s: expected a significant regression, got %+v", metric, row)
+		}
+	}
+	if row := findRow(t, c, "p50"); row.Change == nil || *row.Change < 49 || *row.Change > 51 {
+		t.Errorf("expected p50 change of about +50%%, got %+v", row)
+	}
+	if c.Regressions == 0 {
+		t.Error("expected regressions to be counted")
+	}
+
+	table := comparisonTable(c)
+	if !strings.Contains(table, "Comparison with base.json:") || !strings.Contains(table, "* REGRESSION") {
+		t.Errorf("unexpected table:\n%s", table)
+	}
+}
+
+func TestCompareRunsNoChange(t *testing.T) {
+	baseRep, baseDist := comparisonRun(1000, 0, 100*time.Millisecond)
+	base := &savedRun{report: baseRep, Distribution: baseDist.snapshot()}
+	rep, dist := comparisonRun(1000, 0, 100*time.Millisecond)
+	rep.RequestsPerSec = baseRep.RequestsPerSec
+
+	c := compareRuns("base.json", base, rep, dist, 5)
+	if c.Regressions != 0 {
+		t.Errorf("expected no regressions, got %d:\n%s", c.Regressions, comparisonTable(c))
+	}
+}
+
+func TestCompareRunsWithinTolerance(t *testing.T) {
+	// a 2% slowdown is significant over many requests, but within tolerance
+	baseRep, baseDist := comparisonRun(20000, 0, 100*time.Millisecond)
+	base := &savedRun{report: baseRep, Distribution: baseDist.snapshot()}
+	rep, dist := comparisonRun(20000, 0, 102*time.Millisecond)
+
+	c := compareRuns("base.json", base, rep, dist, 5)
+	row := findRow(t, c, "mean")
+	if !row.Significant || row.Regression {
+		t.Errorf("expected a significant change within tolerance, got %+v", row)
+	}
+}
+
+func TestSaveAndLoadRun(t *testing.T) {
+	rep, dist := comparisonRun(100, 10, 50*time.Millisecond)
+	path := filepath.Join(t.TempDir(), "run.json")
+	if err := saveRun(path, rep, dist); err != nil {
+		t.Fatalf("saveRun failed: %v", err)
+	}
+
+	run, err := loadRun(path)
+	if err != nil {
+		t.Fatalf("loadRun failed: %v", err)
+	}
+	if run.Requests != 100 || run.Failed != 10 || run.Latency.P50 != rep.Latency.P50 {
+		t.Errorf("expected the saved report, got %+v", run.report)
+	}
+	if run.Distribution == nil || run.Distribution.Total != 90 {
+		t.Errorf("expected the saved distribution of 90 requests, got %+v", run.Distribution)
+	}
+
+	if _, err := loadRun(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("expected error for a missing baseline")
+	}
+}
@@ -0,0 +1,376 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// curlRequest is a request imported from a curl command line.
+type curlRequest struct {
+	method     string
+	url        string
+	headers    []string // as "key: value", like -H
+	body       []byte
+	hasBody    bool
+	insecure   bool
+	compressed bool
+	http1      bool
+	timeout    time.Duration
+}
+
+// curlIgnored are curl options that have no bearing on the request, and the
+// number of arguments they take.
+var curlIgnored = map[string]int{
+	"-s": 0, "--silent": 0, "-S": 0, "--show-error": 0, "-v": 0, "--verbose": 0,
+	"-i": 0, "--include": 0, "-L": 0, "--location": 0, "-f": 0, "--fail": 0,
+	"-#": 0, "--progress-bar": 0, "--http2": 0, "--http2-prior-knowledge": 0,
+	"-o": 1, "--output": 1, "--connect-timeout": 1, "--retry": 1, "-w": 1, "--write-out": 1,
+}
+
+// curlShortFlags are the short options, by letter, that take no value and so
+// may be followed by others in a bundle, e.g. -sSk.
+const curlShortFlags = "sSviLf#kGI"
+
+// parseCurl parses a curl command line, such as one copied from a browser's
+// developer tools with "Copy as cURL", into a request.
+func parseCurl(cmd string) (*curlRequest, error) {
+	args, err := shellWords(cmd)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) > 0 && args[0] == "curl" {
+		args = args[1:]
+	}
+
+	c := &curlRequest{}
+	var (
+		data []string
+		get  bool
+		head bool
+	)
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			if c.url != "" {
+				return nil, fmt.Errorf("more than one url: %q and %q", c.url, arg)
+			}
+			c.url = arg
+			continue
+		}
+
+		// options take their value as the next argument, or joined to a
+		// short option, e.g. -XPOST, or after = for a long one; short flags
+		// may be bundled, e.g. -sSk or -kXPOST
+		name, value, joined := arg, "", false
+		switch {
+		case strings.HasPrefix(arg, "--"):
+			name, value, joined = strings.Cut(arg, "=")
+		case len(arg) > 2 && strings.Contains(curlShortFlags, arg[1:2]):
+			name = arg[:2]
+			args[i] = "-" + arg[2:] // the rest of the bundle is next
+			i--
+		case len(arg) > 2:
+			name, value, joined = arg[:2], arg[2:], true
+		}
+		next := func() (string, error) {
+			if joined {
+				return value, nil
+			}
+			if i+1 >= len(args) {
+				return "", fmt.Errorf("option %s needs a value", name)
+			}
+			i++
+			return args[i], nil
+		}
+
+		switch name {
+		case "-X", "--request":
+			c.method, err = next()
+		case "-H", "--header":
+			var h string
+			if h, err = next(); err == nil {
+				if !strings.Contains(h, ":") {
+					return nil, fmt.Errorf("invalid header %q, must be key:value", h)
+				}
+				c.headers = append(c.headers, h)
+			}
+		case "-d", "--data", "--data-ascii", "--data-binary", "--data-raw", "--data-urlencode":
+			var d string
+			if d, err = next(); err == nil {
+				d, err = curlData(name, d)
+				data = append(data, d)
+			}
+		case "-u", "--user":
+			var user string
+			if user, err = next(); err == nil {
+				c.headers = append(c.headers, "Authorization: Basic "+base64.StdEncoding.EncodeToString([]byte(user)))
+			}
+		case "-b", "--cookie":
+			var cookie string
+			if cookie, err = next(); err == nil {
+				if !strings.Contains(cookie, "=") {
+					return nil, fmt.Errorf("cookie files are not supported: %q", cookie)
+				}
+				c.headers = append(c.headers, "Cookie: "+cookie)
+			}
+		case "-A", "--user-agent":
+			var ua string
+			if ua, err = next(); err == nil {
+				c.headers = append(c.headers, "User-Agent: "+ua)
+			}
+		case "-e", "--referer":
+			var ref string
+			if ref, err = next(); err == nil {
+				c.headers = append(c.headers, "Referer: "+ref)
+			}
+		case "--url":
+			c.url, err = next()
+		case "-m", "--max-time":
+			var secs string
+			if secs, err = next(); err == nil {
+				var f float64
+				if f, err = strconv.ParseFloat(secs, 64); err == nil {
+					c.timeout = time.Duration(f * float64(time.Second))
+				}
+			}
+		case "-k", "--insecure":
+			c.insecure = true
+		case "--compressed":
+			c.compressed = true
+		case "--http1.1", "--http1.0":
+			c.http1 = true
+		case "-G", "--get":
+			get = true
+		case "-I", "--head":
+			head = true
+		default:
+			n, ok := curlIgnored[name]
+			if !ok {
+				return nil, fmt.Errorf("unsupported curl option %s", name)
+			}
+			if n > 0 && !joined {
+				i++
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	if c.url == "" {
+		return nil, errors.New("no url in curl command")
+	}
+
+	// like curl, data is sent as a form POST unless -G moves it to the query
+	switch {
+	case get && len(data) > 0:
+		sep := "?"
+		if strings.Contains(c.url, "?") {
+			sep = "&"
+		}
+		c.url += sep + strings.Join(data, "&")
+	case len(data) > 0:
+		c.body, c.hasBody = []byte(strings.Join(data, "&")), true
+		if !hasHeader(c.headers, "Content-Type") {
+			c.headers = append(c.headers, "Content-Type: application/x-www-form-urlencoded")
+		}
+	}
+	if c.method == "" {
+		switch {
+		case head:
+			c.method = http.MethodHead
+		case c.hasBody:
+			c.method = http.MethodPost
+		default:
+			c.method = http.MethodGet
+		}
+	}
+	return c, nil
+}
+
+// apply sets the flags the request maps onto, -m, -H, -k, -t and -h2, except
+// those given explicitly on the command line. Headers given with -H take
+// precedence over the curl command's.
+func (c *curlRequest) apply(explicit map[string]bool) {
+	if !explicit["m"] {
+		*method = c.method
+	}
+	if c.insecure && !explicit["k"] {
+		*insecure = true
+	}
+	if c.timeout > 0 && !explicit["t"] {
+		*timeout = c.timeout
+	}
+	if c.http1 && !explicit["h2"] {
+		*h2 = false
+	}
+	flagHeaders := slices.Clone(headers)
+	for _, h := range c.headers {
+		if k, _, _ := strings.Cut(h, ":"); !hasHeader(flagHeaders, k) {
+			headers = append(headers, h)
+		}
+	}
+}
+
+// hasHeader reports whether headers, as "key: value", include key.
+func hasHeader(headers []string, key string) bool {
+	key = strings.TrimSpace(key)
+	for _, h := range headers {
+		if k, _, _ := strings.Cut(h, ":"); strings.EqualFold(strings.TrimSpace(k), key) {
+			return true
+		}
+	}
+	return false
+}
+
+// curlData returns the data of a curl -d style option: @file reads a file,
+// except for --data-raw, and --data-urlencode encodes its value.
+func curlData(option, d string) (string, error) {
+	if option == "--data-urlencode" {
+		// [name]=content, or [name]@file
+		name, content, hasEq := strings.Cut(d, "=")
+		if !hasEq {
+			if n, path, hasAt := strings.Cut(d, "@"); hasAt {
+				b, err := loadBody("@" + path)
+				if err != nil {
+					return "", err
+				}
+				name, content = n, string(b)
+			} else {
+				name, content = "", d
+			}
+		}
+		if name == "" {
+			return url.QueryEscape(content), nil
+		}
+		return name + "=" + url.QueryEscape(content), nil
+	}
+	if option == "--data-raw" || !strings.HasPrefix(d, "@") {
+		return d, nil
+	}
+	b, err := loadBody(d)
+	if err != nil {
+		return "", err
+	}
+	if option != "--data-binary" {
+		// like curl, -d strips newlines from files
+		b = []byte(strings.NewReplacer("\r", "", "\n", "").Replace(string(b)))
+	}
+	return string(b), nil
+}
+
+// shellWords splits a command line into words as a POSIX shell would, with
+// single, double and ANSI-C ($'...') quoting, backslash escapes and line
+// continuations. Variables and other expansions are not supported.
+func shellWords(s string) ([]string, error) {
+	var (
+		words []string
+		word  strings.Builder
+		in    bool // within a word
+	)
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r':
+			if in {
+				words = append(words, word.String())
+				word.Reset()
+				in = false
+			}
+		case ch == '\\':
+			if i+1 < len(s) {
+				i++
+				if s[i] != '\n' { // a backslash-newline continues the line
+					word.WriteByte(s[i])
+					in = true
+				}
+			}
+		case ch == '\'':
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return nil, errors.New("unterminated ' in curl command")
+			}
+			word.WriteString(s[i+1 : i+1+end])
+			i += end + 1
+			in = true
+		case ch == '$' && i+1 < len(s) && s[i+1] == '\'':
+			n, err := ansiCQuoted(s[i+2:], &word)
+			if err != nil {
+				return nil, err
+			}
+			i += n + 2
+			in = true
+		case ch == '"':
+			i++
+			for ; i < len(s) && s[i] != '"'; i++ {
+				// within double quotes, backslash only escapes these
+				if s[i] == '\\' && i+1 < len(s) && strings.IndexByte("\"\\$`\n", s[i+1]) >= 0 {
+					i++
+					if s[i] == '\n' {
+						continue
+					}
+				}
+				word.WriteByte(s[i])
+			}
+			if i >= len(s) {
+				return nil, errors.New(`unterminated " in curl command`)
+			}
+			in = true
+		default:
+			word.WriteByte(ch)
+			in = true
+		}
+	}
+	if in {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// ansiCQuoted decodes the body of a $'...' string up to its closing quote
+// into word, returning the number of bytes consumed, including the quote.
+func ansiCQuoted(s string, word *strings.Builder) (int, error) {
+	escapes := map[byte]byte{'n': '\n', 't': '\t', 'r': '\r', '\\': '\\', '\'': '\'', '"': '"', 'a': '\a', 'b': '\b', 'e': 0x1b, 'f': '\f', 'v': '\v'}
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\'':
+			return i + 1, nil
+		case s[i] == '\\' && i+1 < len(s):
+			i++
+			if b, ok := escapes[s[i]]; ok {
+				word.WriteByte(b)
+				continue
+			}
+			if s[i] == 'x' || s[i] == 'u' {
+				width := 2
+				if s[i] == 'u' {
+					width = 4
+				}
+				j := i + 1
+				for j < len(s) && j < i+1+width && strings.IndexByte("0123456789abcdefABCDEF", s[j]) >= 0 {
+					j++
+				}
+				if n, err := strconv.ParseUint(s[i+1:j], 16, 32); err == nil {
+					if s[i] == 'x' {
+						word.WriteByte(byte(n))
+					} else {
+						word.WriteRune(rune(n))
+					}
+					i = j - 1
+					continue
+				}
+			}
+			word.WriteByte('\\')
+			word.WriteByte(s[i])
+		default:
+			word.WriteByte(s[i])
+		}
+	}
+	return 0, errors.New("unterminated $' in curl command")
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestShellWords(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{`curl https://example.com`, []string{"curl", "https://example.com"}},
+		{`-H 'Accept: a "b"'`, []string{"-H", `Accept: a "b"`}},
+		{`-H "X-A: \"q\" \$x \n"`, []string{"-H", `X-A: "q" $x \n`}},
+		{"a\\ b \\\n  c", []string{"a b", "c"}},
+		{`--data-raw $'{"a":"x\'y\né"}'`, []string{"--data-raw", "{\"a\":\"x'y\né\"}"}},
+		{`'a'"b"c ''`, []string{"abc", ""}},
+	}
+	for _, tt := range tests {
+		got, err := shellWords(tt.in)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tt.in, err)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%q: expected %q, got %q", tt.in, tt.want, got)
+		}
+	}
+
+	for _, in := range []string{`'open`, `"open`, `$'open`} {
+		if _, err := shellWords(in); err == nil {
+			t.Errorf("%q: expected an error", in)
+		}
+	}
+}
+
+func TestParseCurl(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "body.json")
+	if err := os.WriteFile(path, []byte("{\n\"a\": 1\n}\n"), 0644); err != nil {
+		t.Fatalf("failed to create body file: %v", err)
+	}
+
+	c, err := parseCurl(`curl 'https://example.com/api?x=1' \
+  -X PUT \
+  -H 'Content-Type: application/json' \
+  -H 'Authorization: Bearer a:b' \
+  -u alice:secret -b 'session=abc; theme=dark' \
+  --data-binary @` + path + ` --compressed -k --max-time 2.5 -sS`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.method != "PUT" {
+		t.Errorf("expected method PUT, got %s", c.method)
+	}
+	if c.url != "https://example.com/api?x=1" {
+		t.Errorf("expected url https://example.com/api?x=1, got %s", c.url)
+	}
+	wantHeaders := []string{
+		"Content-Type: application/json",
+		"Authorization: Bearer a:b",
+		"Authorization: Basic YWxpY2U6c2VjcmV0",
+		"Cookie: session=abc; theme=dark",
+	}
+	if !slices.Equal(c.headers, wantHeaders) {
+		t.Errorf("expected headers %q, got %q", wantHeaders, c.headers)
+	}
+	if !c.hasBody || string(c.body) != "{\n\"a\": 1\n}\n" {
+		t.Errorf("expected the file's body unchanged, got %q", c.body)
+	}
+	if !c.insecure || !c.compressed {
+		t.Errorf("expected insecure and compressed, got %v and %v", c.insecure, c.compressed)
+	}
+	if c.timeout != 2500*time.Millisecond {
+		t.Errorf("expected timeout 2.5s, got %v", c.timeout)
+	}
+
+	// -d strips newlines from files, joins values with & and implies a form POST
+	c, err = parseCurl(`curl -d @` + path + ` --data a=1 --data-urlencode 'q=a b&c' --data-raw @raw https://example.com`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.method != "POST" {
+		t.Errorf("expected method POST, got %s", c.method)
+	}
+	if want := `{"a": 1}&a=1&q=a+b%26c&@raw`; string(c.body) != want {
+		t.Errorf("expected body %q, got %q", want, c.body)
+	}
+	if want := []string{"Content-Type: application/x-www-form-urlencoded"}; !slices.Equal(c.headers, want) {
+		t.Errorf("expected headers %q, got %q", want, c.headers)
+	}
+
+	// -G sends the data in the query
+	c, err = parseCurl(`curl -G -d a=1 -d b=2 https://example.com/?x=1`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.method != "GET" || c.hasBody || c.url != "https://example.com/?x=1&a=1&b=2" {
+		t.Errorf("expected GET https://example.com/?x=1&a=1&b=2 with no body, got %s %s with %q", c.method, c.url, c.body)
+	}
+
+	// joined option values
+	c, err = parseCurl(`curl -XDELETE --url=https://example.com/1 -HAccept:text/plain`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.method != "DELETE" || c.url != "https://example.com/1" || !slices.Equal(c.headers, []string{"Accept:text/plain"}) {
+		t.Errorf("expected DELETE https://example.com/1 with Accept, got %s %s with %q", c.method, c.url, c.headers)
+	}
+
+	// bundled short flags, and a bundle ending in an option with its value
+	for cmd, want := range map[string]string{
+		`curl -sSk https://example.com`:           "GET",
+		`curl -kXPOST https://example.com`:        "POST",
+		`curl -LsGkd a=1 https://example.com`:     "GET",
+		`curl -sk -X PUT -H '-kX: 1' example.com`: "PUT",
+	} {
+		c, err = parseCurl(cmd)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %v", cmd, err)
+		}
+		if !c.insecure || c.method != want {
+			t.Errorf("%q: expected insecure %s, got %v %s", cmd, want, c.insecure, c.method)
+		}
+	}
+
+	for _, cmd := range []string{
+		`curl`,
+		`curl -sZ https://example.com`,
+		`curl -X`,
+		`curl --upload-file f https://example.com`,
+		`curl -b cookies.txt https://example.com`,
+		`curl -H NoColon https://example.com`,
+		`curl https://a.example.com https://b.example.com`,
+	} {
+		if _, err := parseCurl(cmd); err == nil {
+			t.Errorf("%q: expected an error", cmd)
+		}
+	}
+}
+
+func TestCurlApply(t *testing.T) {
+	m, k, to, http2, hs := *method, *insecure, *timeout, *h2, headers
+	defer func() { *method, *insecure, *timeout, *h2, headers = m, k, to, http2, hs }()
+
+	*method, *insecure, *timeout, *h2 = "GET", false, 30*time.Second, true
+	headers = headerSlice{"authorization: Bearer flag"}
+	c := &curlRequest{
+		method:   "POST",
+		headers:  []string{"Authorization: Bearer curl", "Accept: */*"},
+		insecure: true,
+		http1:    true,
+		timeout:  time.Second,
+	}
+	c.apply(map[string]bool{"t": true, "H": true})
+
+	if *method != "POST" || !*insecure || *h2 {
+		t.Errorf("expected POST, insecure and no HTTP/2, got %s, %v and %v", *method, *insecure, *h2)
+	}
+	if *timeout != 30*time.Second {
+		t.Errorf("expected the explicit timeout to be kept, got %v", *timeout)
+	}
+	if want := (headerSlice{"authorization: Bearer flag", "Accept: */*"}); !slices.Equal(headers, want) {
+		t.Errorf("expected headers %q, got %q", want, headers)
+	}
+}
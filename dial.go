@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// addrRule redirects connections to host:port to toHost:toPort. An empty
+// host or port matches any, and an empty toHost or toPort keeps the original.
+// Only where connections go changes: the Host header and TLS server name of
+// requests are still those of the URL.
+type addrRule struct {
+	expr           string // as given
+	host, port     string
+	toHost, toPort string
+}
+
+// resolveSlice is for parsing repeatable -resolve flags
+type resolveSlice []addrRule
+
+func (r *resolveSlice) String() string { return strings.Join(ruleExprs(*r), " ") }
+func (r *resolveSlice) Set(v string) error {
+	rule, err := parseResolve(v)
+	if err != nil {
+		return err
+	}
+	*r = append(*r, rule)
+	return nil
+}
+
+// connectToSlice is for parsing repeatable -connect-to flags
+type connectToSlice []addrRule
+
+func (c *connectToSlice) String() string { return strings.Join(ruleExprs(*c), " ") }
+func (c *connectToSlice) Set(v string) error {
+	rule, err := parseConnectTo(v)
+	if err != nil {
+		return err
+	}
+	*c = append(*c, rule)
+	return nil
+}
+
+// ruleExprs returns the rules as given, or nil without rules.
+func ruleExprs(rules []addrRule) []string {
+	var exprs []string
+	for _, r := range rules {
+		exprs = append(exprs, r.expr)
+	}
+	return exprs
+}
+
+// parseResolve parses a rule of the form HOST:PORT:ADDR, like curl's
+// --resolve: connections to HOST on PORT go to the IP address ADDR instead of
+// the addresses HOST resolves to.
+func parseResolve(expr string) (addrRule, error) {
+	host, rest, _ := strings.Cut(expr, ":")
+	port, addr, ok := strings.Cut(rest, ":")
+	if !ok || host == "" || port == "" || addr == "" {
+		return addrRule{}, fmt.Errorf("invalid resolve %q, must be HOST:PORT:ADDR", expr)
+	}
+	if err := checkPort(port); err != nil {
+		return addrRule{}, fmt.Errorf("invalid resolve %q: %w", expr, err)
+	}
+	addr = strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+	if net.ParseIP(addr) == nil {
+		return addrRule{}, fmt.Errorf("invalid resolve %q: %q is not an IP address", expr, addr)
+	}
+	return addrRule{expr: expr, host: host, port: port, toHost: addr}, nil
+}
+
+// parseConnectTo parses a rule of the form HOST1:PORT1:HOST2:PORT2, like
+// curl's --connect-to: connections to HOST1 on PORT1 go to HOST2 on PORT2
+// instead. Any of the four may be left empty: HOST1 and PORT1 then match any
+// host or port, and HOST2 and PORT2 keep the original. IPv6 addresses are
+// written in brackets.
+func parseConnectTo(expr string) (addrRule, error) {
+	invalid := fmt.Errorf("invalid connect-to %q, must be HOST1:PORT1:HOST2:PORT2", expr)
+	fields, rest := make([]string, 4), expr
+	for i := range fields {
+		var ok bool
+		switch {
+		case i == len(fields)-1:
+			fields[i] = rest
+		case i%2 == 0 && strings.HasPrefix(rest, "["): // an IPv6 host
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return addrRule{}, invalid
+			}
+			fields[i] = rest[1:end]
+			if rest, ok = strings.CutPrefix(rest[end+1:], ":"); !ok {
+				return addrRule{}, invalid
+			}
+		default:
+			if fields[i], rest, ok = strings.Cut(rest, ":"); !ok {
+				return addrRule{}, invalid
+			}
+		}
+	}
+	for _, port := range []string{fields[1], fields[3]} {
+		if port == "" {
+			continue
+		}
+		if err := checkPort(port); err != nil {
+			return addrRule{}, fmt.Errorf("invalid connect-to %q: %w", expr, err)
+		}
+	}
+	return addrRule{expr: expr, host: fields[0], port: fields[1], toHost: fields[2], toPort: fields[3]}, nil
+}
+
+func checkPort(port string) error {
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("invalid port %q", port)
+	}
+	return nil
+}
+
+func (r addrRule) matches(host, port string) bool {
+	return (r.host == "" || strings.EqualFold(r.host, host)) && (r.port == "" || r.port == port)
+}
+
+// redirect returns where a connection to host:port goes under r.
+func (r addrRule) redirect(host, port string) (string, string) {
+	if r.toHost != "" {
+		host = r.toHost
+	}
+	if r.toPort != "" {
+		port = r.toPort
+	}
+	return host, port
+}
+
+// dialAddr returns the address a connection to addr goes to: like curl, the
+// first matching -connect-to rule applies, then the first -resolve rule
+// matching the result.
+func dialAddr(addr string, resolve, connectTo []addrRule) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	for _, rules := range [][]addrRule{connectTo, resolve} {
+		for _, r := range rules {
+			if r.matches(host, port) {
+				host, port = r.redirect(host, port)
+				break
+			}
+		}
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// dialContext returns the DialContext of a transport that applies the
+// -resolve and -connect-to rules, or nil without rules, for the default.
+func dialContext(resolve, connectTo []addrRule) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	if len(resolve) == 0 && len(connectTo) == 0 {
+		return nil
+	}
+	var d net.Dialer
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return d.DialContext(ctx, network, dialAddr(addr, resolve, connectTo))
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseAddrRules(t *testing.T) {
+	for _, tc := range []struct {
+		expr      string
+		connectTo bool
+		want      addrRule
+	}{
+		{"api.example.com:443:10.0.0.7", false, addrRule{host: "api.example.com", port: "443", toHost: "10.0.0.7"}},
+		{"api.example.com:443:[2001:db8::7]", false, addrRule{host: "api.example.com", port: "443", toHost: "2001:db8::7"}},
+		{"api.example.com:443:2001:db8::7", false, addrRule{host: "api.example.com", port: "443", toHost: "2001:db8::7"}},
+		{"api.example.com:443:canary.internal:8443", true, addrRule{host: "api.example.com", port: "443", toHost: "canary.internal", toPort: "8443"}},
+		{"::10.0.0.7:", true, addrRule{toHost: "10.0.0.7"}},
+		{"[2001:db8::1]:80:[2001:db8::7]:", true, addrRule{host: "2001:db8::1", port: "80", toHost: "2001:db8::7"}},
+		{":::8080", true, addrRule{toPort: "8080"}},
+	} {
+		var got addrRule
+		var err error
+		if tc.connectTo {
+			got, err = parseConnectTo(tc.expr)
+		} else {
+			got, err = parseResolve(tc.expr)
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.expr, err)
+			continue
+		}
+		tc.want.expr = tc.expr
+		if got != tc.want {
+			t.Errorf("%s: expected %+v, got %+v", tc.expr, tc.want, got)
+		}
+	}
+
+	for _, expr := range []string{"api.example.com", "api.example.com:443", ":443:10.0.0.7", "api.example.com::10.0.0.7", "api.example.com:https:10.0.0.7", "api.example.com:443:pod-7"} {
+		if _, err := parseResolve(expr); err == nil {
+			t.Errorf("resolve %s: expected an error", expr)
+		}
+	}
+	for _, expr := range []string{"a:443:b", "a:443:b:8443:c", "a:port:b:", "a:443:b:70000", "[2001:db8::1:443::", "[2001:db8::1]443::"} {
+		if _, err := parseConnectTo(expr); err == nil {
+			t.Errorf("connect-to %s: expected an error", expr)
+		}
+	}
+}
+
+func TestDialAddr(t *testing.T) {
+	rule := func(expr string, parse func(string) (addrRule, error)) addrRule {
+		r, err := parse(expr)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return r
+	}
+	resolve := []addrRule{
+		rule("api.example.com:443:10.0.0.7", parseResolve),
+		rule("canary.internal:8443:10.0.0.9", parseResolve),
+	}
+	connectTo := []addrRule{
+		rule("api.example.com:80:canary.internal:8443", parseConnectTo),
+		rule("API.example.com::[2001:db8::7]:", parseConnectTo),
+	}
+	for addr, want := range map[string]string{
+		"api.example.com:443":   "[2001:db8::7]:443", // the first matching -connect-to applies
+		"api.example.com:80":    "10.0.0.9:8443",     // then -resolve, on its result
+		"canary.internal:8443":  "10.0.0.9:8443",
+		"other.example.com:443": "other.example.com:443",
+		"[2001:db8::1]:443":     "[2001:db8::1]:443",
+	} {
+		if got := dialAddr(addr, resolve, connectTo); got != want {
+			t.Errorf("%s: expected %s, got %s", addr, want, got)
+		}
+	}
+	if got := dialAddr("api.example.com:443", resolve, nil); got != "10.0.0.7:443" {
+		t.Errorf("expected 10.0.0.7:443, got %s", got)
+	}
+	if dialContext(nil, nil) != nil {
+		t.Error("expected the default dialer without rules")
+	}
+}
+
+func TestResolveKeepsHostAndSNI(t *testing.T) {
+	pki := newTestPKI(t)
+	serverCert, _, _ := pki.issue("api.internal", x509.ExtKeyUsageServerAuth)
+	var sni string
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Host))
+	}))
+	srv.TLS = &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			sni = hello.ServerName
+			return nil, nil
+		},
+	}
+	srv.StartTLS()
+	defer srv.Close()
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+
+	cfg, err := newTLSConfig(tlsOptions{caFile: filepath.Join(pki.dir, "ca.pem")})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resolve, err := parseResolve("api.internal:" + port + ":127.0.0.1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	client := &http.Client{
+		Transport: &http.Transport{TLSClientConfig: cfg, DialContext: dialContext([]addrRule{resolve}, nil)},
+		Timeout:   5 * time.Second,
+	}
+	resp, err := client.Get("https://api.internal:" + port + "/")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "api.internal:"+port || sni != "api.internal" {
+		t.Errorf("expected Host api.internal:%s and SNI api.internal, got %q and %q", port, body, sni)
+	}
+}
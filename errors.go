@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"syscall"
+	"text/template"
+)
+
+// errorCount is the number of errors in a category, with an example message.
+type errorCount struct {
+	Count   int    `json:"count"`
+	Example string `json:"example"`
+}
+
+// errorCategory normalizes a request error into a category, so that failures
+// can be counted by cause rather than by their (often unique) messages.
+func errorCategory(err error) string {
+	var (
+		dnsErr     *net.DNSError
+		netErr     net.Error
+		recordErr  tls.RecordHeaderError
+		alertErr   tls.AlertError
+		verifyErr  *tls.CertificateVerificationError
+		unknownCA  x509.UnknownAuthorityError
+		hostErr    x509.HostnameError
+		invalidErr x509.CertificateInvalidError
+		execErr    template.ExecError
+		extractErr *extractError
+		checkErr   *checkError
+		statusErr  *statusError
+	)
+	msg := err.Error()
+	switch {
+	case errors.Is(err, context.Canceled):
+		return "context canceled"
+	case errors.As(err, &dnsErr):
+		return "DNS failure"
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "connection refused"
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE):
+		return "connection reset"
+	case errors.As(err, &recordErr), errors.As(err, &alertErr), errors.As(err, &verifyErr),
+		errors.As(err, &unknownCA), errors.As(err, &hostErr), errors.As(err, &invalidErr),
+		strings.Contains(msg, "tls: "):
+		return "TLS failure"
+	case strings.Contains(msg, "stopped after") && strings.Contains(msg, "redirects"):
+		return "too many redirects" // from http.Client's default redirect policy
+	case errors.As(err, &execErr):
+		return "template error"
+	case errors.As(err, &extractErr):
+		return "extraction error"
+	case errors.As(err, &checkErr):
+		return "check failed"
+	case errors.As(err, &statusErr):
+		return fmt.Sprintf("%dxx status", statusErr.code/100)
+	default:
+		return "other"
+	}
+}
+
+// errorDistribution formats error counts by category, most frequent first.
+func errorDistribution(errs map[string]errorCount) string {
+	keys := make([]string, 0, len(errs))
+	for k := range errs {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, func(a, b string) int {
+		if errs[a].Count != errs[b].Count {
+			return errs[b].Count - errs[a].Count
+		}
+		return strings.Compare(a, b)
+	})
+
+	var sb strings.Builder
+	sb.WriteString("\nError distribution:\n")
+	for _, k := range keys {
+		fmt.Fprintf(&sb, "  [%d] %s, e.g. %s\n", errs[k].Count, k, errs[k].Example)
+	}
+	return sb.String()
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// timeoutError is a net.Error that reports a timeout
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestErrorCategory(t *testing.T) {
+	wrap := func(err error) error {
+		return &url.Error{Op: "Get", URL: "http://example.com", Err: err}
+	}
+	opErr := func(errno syscall.Errno) error {
+		return &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", errno)}
+	}
+
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{wrap(context.Canceled), "context canceled"},
+		{wrap(context.DeadlineExceeded), "timeout"},
+		{wrap(timeoutError{}), "timeout"},
+		{wrap(&net.DNSError{Err: "no such host", Name: "example.invalid"}), "DNS failure"},
+		{wrap(opErr(syscall.ECONNREFUSED)), "connection refused"},
+		{wrap(opErr(syscall.ECONNRESET)), "connection reset"},
+		{wrap(errors.New("tls: handshake failure")), "TLS failure"},
+		{wrap(errors.New("stopped after 10 redirects")), "too many redirects"},
+		{wrap(errors.New("something else")), "other"},
+	}
+	for _, tt := range tests {
+		if got := errorCategory(tt.err); got != tt.want {
+			t.Errorf("errorCategory(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
+
+// TestErrorCategoryFromClient tests categories of errors returned by a real client
+func TestErrorCategoryFromClient(t *testing.T) {
+	// Test untrusted certificate
+	tlsServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer tlsServer.Close()
+
+	req, _ := http.NewRequestWithContext(t.Context(), http.MethodGet, tlsServer.URL, nil)
+	_, err := http.DefaultClient.Do(req)
+	if got := errorCategory(err); got != "TLS failure" {
+		t.Errorf("expected TLS failure for %v, got %q", err, got)
+	}
+
+	// Test redirect loop
+	var loop *httptest.Server
+	loop = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, loop.URL, http.StatusFound)
+	}))
+	defer loop.Close()
+
+	req, _ = http.NewRequestWithContext(t.Context(), http.MethodGet, loop.URL, nil)
+	_, err = http.DefaultClient.Do(req)
+	if got := errorCategory(err); got != "too many redirects" {
+		t.Errorf("expected too many redirects for %v, got %q", err, got)
+	}
+
+	// Test closed port
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	addr := ln.Addr().String()
+	_ = ln.Close()
+
+	req, _ = http.NewRequestWithContext(t.Context(), http.MethodGet, "http://"+addr, nil)
+	_, err = http.DefaultClient.Do(req)
+	if got := errorCategory(err); got != "connection refused" {
+		t.Errorf("expected connection refused for %v, got %q", err, got)
+	}
+}
+
+func TestErrorDistribution(t *testing.T) {
+	out := errorDistribution(map[string]errorCount{
+		"timeout":            {Count: 2, Example: "deadline exceeded"},
+		"connection refused": {Count: 5, Example: "dial tcp: connection refused"},
+	})
+
+	expected := fmt.Sprint(
+		"\nError distribution:\n",
+		"  [5] connection refused, e.g. dial tcp: connection refused\n",
+		"  [2] timeout, e.g. deadline exceeded\n",
+	)
+	if out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+}
+
+func TestStatsErrors(t *testing.T) {
+	s := newStats(3)
+	s.add(record{failed: true, errMsg: "first timeout", errCategory: "timeout"})
+	s.add(record{failed: true, errMsg: "second timeout", errCategory: "timeout"})
+	s.add(record{latency: 1, status: 200})
+
+	e := s.errors["timeout"]
+	if e.Count != 2 || e.Example != "first timeout" {
+		t.Errorf("expected 2 timeouts with the first as example, got %+v", e)
+	}
+	if len(s.errors) != 1 || !strings.Contains(errorDistribution(s.errors), "[2] timeout") {
+		t.Errorf("unexpected errors %+v", s.errors)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// exportedRecord is a record as written to an export file. Durations are in
+// seconds, and offset is the time the request was sent, from the run start.
+type exportedRecord struct {
+	Offset        float64 `json:"offset"`
+	Worker        int     `json:"worker"`
+	Stage         int     `json:"stage"`
+	Target        int     `json:"target"`
+	Latency       float64 `json:"latency"`
+	Wait          float64 `json:"wait"`
+	Status        int     `json:"status"`
+	Bytes         int64   `json:"bytes"`
+	Failed        bool    `json:"failed"`
+	Error         string  `json:"error,omitempty"`
+	ErrorCategory string  `json:"error_category,omitempty"`
+	Reused        bool    `json:"reused"`
+	DNS           float64 `json:"dns"`
+	Connect       float64 `json:"connect"`
+	TLS           float64 `json:"tls"`
+	Write         float64 `json:"write"`
+	TTFB          float64 `json:"ttfb"`
+	Transfer      float64 `json:"transfer"`
+	TLSVersion    string  `json:"tls_version,omitempty"`
+	TLSCipher     string  `json:"tls_cipher,omitempty"`
+	ALPN          string  `json:"alpn,omitempty"`
+	TLSResumed    bool    `json:"tls_resumed"`
+}
+
+var exportHeader = []string{
+	"offset", "worker", "stage", "target", "latency", "wait", "status", "bytes", "failed", "error", "error_category",
+	"reused", "dns", "connect", "tls", "write", "ttfb", "transfer", "tls_version", "tls_cipher", "alpn", "tls_resumed",
+}
+
+func (e exportedRecord) csvRow() []string {
+	secs := func(f float64) string { return strconv.FormatFloat(f, 'f', 6, 64) }
+	return []string{
+		secs(e.Offset), strconv.Itoa(e.Worker), strconv.Itoa(e.Stage), strconv.Itoa(e.Target), secs(e.Latency), secs(e.Wait),
+		strconv.Itoa(e.Status), strconv.FormatInt(e.Bytes, 10), strconv.FormatBool(e.Failed), e.Error, e.ErrorCategory,
+		strconv.FormatBool(e.Reused), secs(e.DNS), secs(e.Connect), secs(e.TLS), secs(e.Write), secs(e.TTFB), secs(e.Transfer),
+		e.TLSVersion, e.TLSCipher, e.ALPN, strconv.FormatBool(e.TLSResumed),
+	}
+}
+
+// recordWriter streams every record to a file, as CSV or newline-delimited
+// JSON, as requests complete. It is not safe for concurrent use.
+type recordWriter struct {
+	f     *os.File
+	buf   *bufio.Writer
+	csv   *csv.Writer   // nil unless writing CSV
+	enc   *json.Encoder // nil unless writing NDJSON
+	start time.Time
+	err   error // the first write error
+}
+
+// exportFormat returns the format to export to path in: format if set,
+// otherwise inferred from the file extension.
+func exportFormat(path, format string) (string, error) {
+	if format == "" {
+		switch filepath.Ext(path) {
+		case ".csv":
+			format = "csv"
+		case ".ndjson", ".jsonl":
+			format = "ndjson"
+		default:
+			return "", fmt.Errorf("cannot infer export format from %q, use -export-format", path)
+		}
+	}
+	if format != "csv" && format != "ndjson" {
+		return "", fmt.Errorf("unknown export format %q, must be csv or ndjson", format)
+	}
+	return format, nil
+}
+
+// newRecordWriter creates path and writes records to it in format, with
+// offsets measured from start.
+func newRecordWriter(path, format string, start time.Time) (*recordWriter, error) {
+	f, err := os.Create(path) //nolint:gosec // User explicitly specified file path via -export flag
+	if err != nil {
+		return nil, err
+	}
+	rw := &recordWriter{f: f, buf: bufio.NewWriter(f), start: start}
+	if format == "csv" {
+		rw.csv = csv.NewWriter(rw.buf)
+		rw.err = rw.csv.Write(exportHeader)
+	} else {
+		rw.enc = json.NewEncoder(rw.buf)
+	}
+	return rw, nil
+}
+
+func (rw *recordWriter) write(rec record) {
+	if rw.err != nil {
+		return
+	}
+	e := exportedRecord{
+		Offset:        rec.sent.Sub(rw.start).Seconds(),
+		Worker:        rec.worker,
+		Stage:         rec.stage,
+		Target:        rec.target,
+		Latency:       rec.latency.Seconds(),
+		Wait:          rec.wait.Seconds(),
+		Status:        rec.status,
+		Bytes:         rec.size,
+		Failed:        rec.failed,
+		Error:         rec.errMsg,
+		ErrorCategory: rec.errCategory,
+		Reused:        rec.reused,
+		DNS:           rec.phases[phaseDNS].Seconds(),
+		Connect:       rec.phases[phaseConnect].Seconds(),
+		TLS:           rec.phases[phaseTLS].Seconds(),
+		Write:         rec.phases[phaseWrite].Seconds(),
+		TTFB:          rec.phases[phaseTTFB].Seconds(),
+		Transfer:      rec.phases[phaseTransfer].Seconds(),
+		TLSVersion:    rec.tls.versionName(),
+		TLSCipher:     rec.tls.cipherName(),
+		ALPN:          rec.tls.alpn,
+		TLSResumed:    rec.tls.resumed,
+	}
+	if rw.csv != nil {
+		rw.err = rw.csv.Write(e.csvRow())
+	} else {
+		rw.err = rw.enc.Encode(e)
+	}
+}
+
+// Close flushes buffered records and closes the file. It returns the first
+// error encountered while writing, if any.
+func (rw *recordWriter) Close() error {
+	if rw.csv != nil {
+		rw.csv.Flush()
+		if rw.err == nil {
+			rw.err = rw.csv.Error()
+		}
+	}
+	if err := rw.buf.Flush(); rw.err == nil {
+		rw.err = err
+	}
+	if err := rw.f.Close(); rw.err == nil {
+		rw.err = err
+	}
+	return rw.err
+}
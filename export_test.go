@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportFormat(t *testing.T) {
+	tests := []struct {
+		path, format string
+		want         string
+		wantErr      bool
+	}{
+		{"out.csv", "", "csv", false},
+		{"out.ndjson", "", "ndjson", false},
+		{"out.jsonl", "", "ndjson", false},
+		{"out.txt", "", "", true},
+		{"out.txt", "csv", "csv", false},
+		{"out.csv", "xml", "", true},
+	}
+	for _, tt := range tests {
+		got, err := exportFormat(tt.path, tt.format)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("exportFormat(%q, %q) = %q, %v; want %q, error %v", tt.path, tt.format, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+// exportTestRecords returns a successful and a failed record, sent 1s and 2s after start
+func exportTestRecords(start time.Time) []record {
+	return []record{
+		{
+			sent: start.Add(time.Second), worker: 3, latency: 100 * time.Millisecond, status: 200, size: 42, reused: true,
+			phases: [numPhases]time.Duration{phaseTTFB: 80 * time.Millisecond},
+		},
+		{
+			sent: start.Add(2 * time.Second), worker: 4, latency: 5 * time.Millisecond, failed: true,
+			errMsg: "dial tcp: connection refused", errCategory: "connection refused",
+		},
+	}
+}
+
+func TestRecordWriterCSV(t *testing.T) {
+	start := time.Now()
+	path := filepath.Join(t.TempDir(), "records.csv")
+	rw, err := newRecordWriter(path, "csv", start)
+	if err != nil {
+		t.Fatalf("newRecordWriter failed: %v", err)
+	}
+	for _, rec := range exportTestRecords(start) {
+		rw.write(rec)
+	}
+	if err := rw.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open export: %v", err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatalf("export is not valid CSV: %v", err)
+	}
+
+	if len(rows) != 3 {
+		t.Fatalf("expected header and 2 rows, got %d rows", len(rows))
+	}
+	row := map[string]string{}
+	for i, name := range rows[0] {
+		row[name] = rows[1][i]
+	}
+	expected := map[string]string{
+		"offset": "1.000000", "worker": "3", "latency": "0.100000", "status": "200",
+		"bytes": "42", "failed": "false", "reused": "true", "ttfb": "0.080000",
+	}
+	for k, v := range expected {
+		if row[k] != v {
+			t.Errorf("expected %s=%q, got %q", k, v, row[k])
+		}
+	}
+	if rows[2][9] != "dial tcp: connection refused" {
+		t.Errorf("expected error message in second row, got %v", rows[2])
+	}
+}
+
+func TestRecordWriterNDJSON(t *testing.T) {
+	start := time.Now()
+	path := filepath.Join(t.TempDir(), "records.ndjson")
+	rw, err := newRecordWriter(path, "ndjson", start)
+	if err != nil {
+		t.Fatalf("newRecordWriter failed: %v", err)
+	}
+	for _, rec := range exportTestRecords(start) {
+		rw.write(rec)
+	}
+	if err := rw.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open export: %v", err)
+	}
+	defer f.Close()
+
+	var lines []exportedRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e exportedRecord
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("line is not valid JSON: %v: %s", err, scanner.Text())
+		}
+		lines = append(lines, e)
+	}
+
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d", len(lines))
+	}
+	if lines[0].Offset != 1 || lines[0].Worker != 3 || lines[0].Status != 200 || !lines[0].Reused || lines[0].TTFB != 0.08 {
+		t.Errorf("unexpected first record %+v", lines[0])
+	}
+	if !lines[1].Failed || lines[1].ErrorCategory != "connection refused" || lines[1].Offset != 2 {
+		t.Errorf("unexpected second record %+v", lines[1])
+	}
+}
+
+func TestResultSetExport(t *testing.T) {
+	start := time.Now()
+	path := filepath.Join(t.TempDir(), "records.ndjson")
+	rw, err := newRecordWriter(path, "ndjson", start)
+	if err != nil {
+		t.Fatalf("newRecordWriter failed: %v", err)
+	}
+
+	rs := &resultSet{start: start, export: rw}
+	for _, rec := range exportTestRecords(start) {
+		rs.add(rec)
+	}
+	if err := rw.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read export: %v", err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 2 {
+		t.Errorf("expected 2 exported records, got %d", lines)
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// extractor captures a variable from a response: from its JSON body by
+// JSONPath, its body by regular expression, a header, or a Set-Cookie.
+type extractor struct {
+	name string // of the variable, {{.name}}
+	from string // json, regex, header or cookie
+	expr string
+	path []pathElem
+	re   *regexp.Regexp
+}
+
+// extractSpec is an extractor as written in a scenario file. Exactly one
+// field must be set.
+type extractSpec struct {
+	JSON   string `yaml:"json"`   // JSONPath, e.g. $.data.token or $.items[0].id
+	Regex  string `yaml:"regex"`  // the first group, or the whole match if none
+	Header string `yaml:"header"` // the first value of a response header
+	Cookie string `yaml:"cookie"` // the value of a cookie set by the response
+}
+
+// extractError is a failure to capture a variable from a response.
+type extractError struct {
+	name string
+	err  error
+}
+
+func (e *extractError) Error() string { return fmt.Sprintf("extract %s: %v", e.name, e.err) }
+func (e *extractError) Unwrap() error { return e.err }
+
+func newExtractor(name string, spec extractSpec) (*extractor, error) {
+	x, set := &extractor{name: name}, 0
+	for from, expr := range map[string]string{"json": spec.JSON, "regex": spec.Regex, "header": spec.Header, "cookie": spec.Cookie} {
+		if expr != "" {
+			set++
+			x.from, x.expr = from, expr
+		}
+	}
+	if set != 1 {
+		return nil, fmt.Errorf("extract %s: exactly one of json, regex, header or cookie must be set", name)
+	}
+
+	var err error
+	switch x.from {
+	case "json":
+		x.path, err = parseJSONPath(x.expr)
+	case "regex":
+		x.re, err = regexp.Compile(x.expr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("extract %s: %w", name, err)
+	}
+	return x, nil
+}
+
+// needsBody reports whether x reads the response body.
+func (x *extractor) needsBody() bool { return x.from == "json" || x.from == "regex" }
+
+// extract returns the value of x in a response with the given body.
+func (x *extractor) extract(resp *http.Response, body []byte) (string, error) {
+	switch x.from {
+	case "json":
+		doc, err := decodeJSON(body)
+		if err != nil {
+			return "", &extractError{x.name, fmt.Errorf("body is not JSON: %w", err)}
+		}
+		v, err := lookupJSONPath(doc, x.path)
+		if err == nil && v == nil {
+			err = errors.New("value is null")
+		}
+		if err != nil {
+			return "", &extractError{x.name, fmt.Errorf("%s: %w", x.expr, err)}
+		}
+		return jsonString(v), nil
+	case "regex":
+		m := x.re.FindSubmatch(body)
+		if m == nil {
+			return "", &extractError{x.name, fmt.Errorf("%s does not match the body", x.expr)}
+		}
+		return string(m[min(1, len(m)-1)]), nil
+	case "header":
+		if v := resp.Header.Values(x.expr); len(v) > 0 {
+			return v[0], nil
+		}
+		return "", &extractError{x.name, fmt.Errorf("no %s header", x.expr)}
+	default:
+		for _, c := range resp.Cookies() {
+			if c.Name == x.expr {
+				return c.Value, nil
+			}
+		}
+		return "", &extractError{x.name, fmt.Errorf("no %s cookie set", x.expr)}
+	}
+}
+
+// pathElem is one step of a JSONPath: an object key, or an array index if
+// key is empty.
+type pathElem struct {
+	key   string
+	index int
+}
+
+// parseJSONPath parses the subset of JSONPath that selects a single value:
+// $ followed by .key, ['key'] and [index] steps. Negative indexes count from
+// the end of an array. The leading $ may be left out.
+func parseJSONPath(path string) ([]pathElem, error) {
+	s := strings.TrimPrefix(path, "$")
+	if s != "" && s[0] != '.' && s[0] != '[' {
+		s = "." + s // a bare key, e.g. data.token
+	}
+	var elems []pathElem
+	for s != "" {
+		switch s[0] {
+		case '.':
+			end := strings.IndexAny(s[1:], ".[") + 1
+			if end == 0 {
+				end = len(s)
+			}
+			if end == 1 {
+				return nil, fmt.Errorf("invalid JSONPath %q: empty key", path)
+			}
+			elems = append(elems, pathElem{key: s[1:end]})
+			s = s[end:]
+		case '[':
+			end := strings.IndexByte(s, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid JSONPath %q: missing ]", path)
+			}
+			sub := s[1:end]
+			if len(sub) >= 2 && (sub[0] == '\'' || sub[0] == '"') && sub[len(sub)-1] == sub[0] {
+				elems = append(elems, pathElem{key: sub[1 : len(sub)-1]})
+			} else {
+				i, err := strconv.Atoi(sub)
+				if err != nil {
+					return nil, fmt.Errorf("invalid JSONPath %q: %q is not an index or quoted key", path, sub)
+				}
+				elems = append(elems, pathElem{index: i})
+			}
+			s = s[end+1:]
+		default:
+			return nil, fmt.Errorf("invalid JSONPath %q at %q", path, s)
+		}
+	}
+	return elems, nil
+}
+
+// lookupJSONPath returns the value at path in a decoded JSON document.
+func lookupJSONPath(doc any, path []pathElem) (any, error) {
+	v := doc
+	for _, e := range path {
+		if e.key != "" {
+			obj, ok := v.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("%s: not an object", e.key)
+			}
+			if v, ok = obj[e.key]; !ok {
+				return nil, fmt.Errorf("no key %s", e.key)
+			}
+			continue
+		}
+		arr, ok := v.([]any)
+		if !ok {
+			return nil, fmt.Errorf("[%d]: not an array", e.index)
+		}
+		i := e.index
+		if i < 0 {
+			i += len(arr)
+		}
+		if i < 0 || i >= len(arr) {
+			return nil, fmt.Errorf("index %d out of range of %d elements", e.index, len(arr))
+		}
+		v = arr[i]
+	}
+	return v, nil
+}
+
+// decodeJSON decodes a JSON body, keeping numbers as json.Number so that
+// they are not rounded, as float64 would round IDs above 2^53.
+func decodeJSON(body []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("invalid data after the top-level value")
+	}
+	return doc, nil
+}
+
+// jsonString formats a JSON value as a variable: strings as they are, numbers
+// as written, and other values as JSON.
+func jsonString(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
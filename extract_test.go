@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestParseJSONPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want []pathElem
+	}{
+		{"$", nil},
+		{"$.token", []pathElem{{key: "token"}}},
+		{"data.token", []pathElem{{key: "data"}, {key: "token"}}},
+		{"$.items[0].id", []pathElem{{key: "items"}, {index: 0}, {key: "id"}}},
+		{"$['a.b'][-1]", []pathElem{{key: "a.b"}, {index: -1}}},
+	}
+	for _, tt := range tests {
+		got, err := parseJSONPath(tt.path)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.path, err)
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.path, tt.want, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: expected %v, got %v", tt.path, tt.want, got)
+				break
+			}
+		}
+	}
+
+	for _, path := range []string{"$.", "$.a..b", "$[0", "$[x]", "$[0]]"} {
+		if _, err := parseJSONPath(path); err == nil {
+			t.Errorf("%s: expected an error", path)
+		}
+	}
+}
+
+func TestExtract(t *testing.T) {
+	resp := &http.Response{Header: http.Header{
+		"X-Request-Id": {"r-1"},
+		"Set-Cookie":   {"theme=dark", "session=abc123; Path=/; HttpOnly"},
+	}}
+	body := []byte(`{"data": {"token": "t0k", "ids": [7, 8.5], "ok": true, "none": null, "order": 12345678901234567890}}`)
+	html := []byte(`<input name="csrf" value="c5">`)
+
+	tests := []struct {
+		spec extractSpec
+		body []byte
+		want string
+	}{
+		{extractSpec{JSON: "$.data.token"}, body, "t0k"},
+		{extractSpec{JSON: "$.data.ids[-1]"}, body, "8.5"},
+		{extractSpec{JSON: "$.data.ids"}, body, "[7,8.5]"},
+		{extractSpec{JSON: "$.data.ok"}, body, "true"},
+		{extractSpec{JSON: "$.data.order"}, body, "12345678901234567890"},
+		{extractSpec{JSON: "$"}, []byte(`[1e3, 12345678901234567890]`), "[1e3,12345678901234567890]"},
+		{extractSpec{Regex: `name="csrf" value="([^"]+)"`}, html, "c5"},
+		{extractSpec{Regex: `c\d`}, html, "c5"},
+		{extractSpec{Header: "x-request-id"}, nil, "r-1"},
+		{extractSpec{Cookie: "session"}, nil, "abc123"},
+	}
+	for _, tt := range tests {
+		x, err := newExtractor("v", tt.spec)
+		if err != nil {
+			t.Errorf("%+v: unexpected error: %v", tt.spec, err)
+			continue
+		}
+		got, err := x.extract(resp, tt.body)
+		if err != nil {
+			t.Errorf("%+v: unexpected error: %v", tt.spec, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%+v: expected %q, got %q", tt.spec, tt.want, got)
+		}
+	}
+
+	for _, spec := range []extractSpec{
+		{JSON: "$.data.missing"},
+		{JSON: "$.data.none"},
+		{JSON: "$.data.token[0]"},
+		{JSON: "$.data.ids[2]"},
+		{Regex: "nope"},
+		{Header: "X-Missing"},
+		{Cookie: "missing"},
+	} {
+		x, err := newExtractor("v", spec)
+		if err != nil {
+			t.Errorf("%+v: unexpected error: %v", spec, err)
+			continue
+		}
+		_, err = x.extract(resp, body)
+		var extractErr *extractError
+		if !errors.As(err, &extractErr) {
+			t.Errorf("%+v: expected an extract error, got %v", spec, err)
+		}
+	}
+
+	// like json.Unmarshal, data after the JSON value is an error
+	x, _ := newExtractor("v", extractSpec{JSON: "$.data.token"})
+	if _, err := x.extract(resp, append(body, " trailing"...)); err == nil {
+		t.Error("expected an error for data after the JSON body")
+	}
+
+	for _, spec := range []extractSpec{{}, {JSON: "$.a", Header: "X-A"}, {Regex: "("}} {
+		if _, err := newExtractor("v", spec); err == nil {
+			t.Errorf("%+v: expected an error", spec)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// feed supplies the variables of request templates, one row per request, as
+// {{.name}}. It is safe for concurrent use.
+type feed struct {
+	rows []map[string]string
+	mode string // sequential, random or per-worker
+}
+
+// feedModes are the ways a feed's rows are consumed: in order, one per
+// request; at random; or one per worker, for all of its requests.
+var feedModes = []string{"sequential", "random", "per-worker"}
+
+// row returns the variables of the seq-th request of the run, sent by worker.
+// Rows wrap around when there are fewer rows than requests or workers.
+func (f *feed) row(seq, worker int) map[string]string {
+	switch f.mode {
+	case "random":
+		return f.rows[rand.IntN(len(f.rows))] //nolint:gosec // row selection doesn't need cryptographic randomness
+	case "per-worker":
+		return f.rows[worker%len(f.rows)]
+	default:
+		return f.rows[seq%len(f.rows)]
+	}
+}
+
+// loadFeed reads the rows of a feed from a CSV file, whose first line names
+// the columns, or from a file of JSON objects, one per line, by its
+// extension: .csv, or .jsonl or .ndjson.
+func loadFeed(path, mode string) (*feed, error) {
+	if !slices.Contains(feedModes, mode) {
+		return nil, fmt.Errorf("unknown feed mode %q, must be one of %s", mode, strings.Join(feedModes, ", "))
+	}
+
+	f, err := os.Open(path) //nolint:gosec // User explicitly specified file path via -feed flag
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var rows []map[string]string
+	switch filepath.Ext(path) {
+	case ".csv":
+		rows, err = readCSVRows(f)
+	case ".jsonl", ".ndjson":
+		rows, err = readJSONRows(f)
+	default:
+		return nil, fmt.Errorf("cannot infer feed format from %q, must be .csv, .jsonl or .ndjson", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%s has no rows", path)
+	}
+	return &feed{rows: rows, mode: mode}, nil
+}
+
+func readCSVRows(r io.Reader) ([]map[string]string, error) {
+	cr := csv.NewReader(r)
+	columns, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var rows []map[string]string
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		row := make(map[string]string, len(columns))
+		for i, col := range columns {
+			row[col] = record[i]
+		}
+		rows = append(rows, row)
+	}
+}
+
+// readJSONRows reads JSON objects, one per line. Values that are not strings
+// are kept as their JSON text, so numbers are rendered as written.
+func readJSONRows(r io.Reader) ([]map[string]string, error) {
+	var rows []map[string]string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal(text, &obj); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		row := make(map[string]string, len(obj))
+		for k, v := range obj {
+			var s string
+			if err := json.Unmarshal(v, &s); err == nil {
+				row[k] = s
+			} else {
+				row[k] = string(v)
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, scanner.Err()
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeFeed writes content to a feed file named name in a temporary directory.
+func writeFeed(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to create feed file: %v", err)
+	}
+	return path
+}
+
+func TestLoadFeedCSV(t *testing.T) {
+	path := writeFeed(t, "users.csv", "user,query\n1,red shoes\n2,\"blue, green\"\n")
+	f, err := loadFeed(path, "sequential")
+	if err != nil {
+		t.Fatalf("loadFeed failed: %v", err)
+	}
+	if len(f.rows) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(f.rows))
+	}
+	if f.rows[1]["user"] != "2" || f.rows[1]["query"] != "blue, green" {
+		t.Errorf("unexpected row %v", f.rows[1])
+	}
+}
+
+func TestLoadFeedJSONL(t *testing.T) {
+	path := writeFeed(t, "skus.jsonl", `{"sku": "A-1", "qty": 3, "gift": true}`+"\n\n"+`{"sku": "B-2", "qty": 1.50}`+"\n")
+	f, err := loadFeed(path, "random")
+	if err != nil {
+		t.Fatalf("loadFeed failed: %v", err)
+	}
+	if len(f.rows) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(f.rows))
+	}
+	if f.rows[0]["sku"] != "A-1" || f.rows[0]["qty"] != "3" || f.rows[0]["gift"] != "true" {
+		t.Errorf("unexpected row %v", f.rows[0])
+	}
+	if f.rows[1]["qty"] != "1.50" {
+		t.Errorf("expected numbers as written, got %q", f.rows[1]["qty"])
+	}
+}
+
+func TestLoadFeedErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name, content, mode string
+	}{
+		{"rows.csv", "a,b\n1,2\n", "shuffled"},
+		{"rows.txt", "a,b\n1,2\n", "sequential"},
+		{"rows.csv", "a,b\n", "sequential"},
+		{"rows.csv", "a,b\n1,2,3\n", "sequential"},
+		{"rows.jsonl", "{\"a\": 1}\nnot json\n", "sequential"},
+	} {
+		if _, err := loadFeed(writeFeed(t, tt.name, tt.content), tt.mode); err == nil {
+			t.Errorf("expected error for %s in %s mode: %q", tt.name, tt.mode, tt.content)
+		}
+	}
+	if _, err := loadFeed(filepath.Join(t.TempDir(), "missing.csv"), "sequential"); err == nil {
+		t.Error("expected error for a missing file")
+	}
+}
+
+func TestFeedRow(t *testing.T) {
+	rows := []map[string]string{{"n": "0"}, {"n": "1"}, {"n": "2"}}
+
+	seq := &feed{rows: rows, mode: "sequential"}
+	for i, want := range []string{"0", "1", "2", "0"} {
+		if got := seq.row(i, 9)["n"]; got != want {
+			t.Errorf("sequential request %d: expected row %s, got %s", i, want, got)
+		}
+	}
+
+	perWorker := &feed{rows: rows, mode: "per-worker"}
+	for i := range 3 {
+		if got := perWorker.row(i, 4)["n"]; got != "1" {
+			t.Errorf("per-worker request %d: expected row 1 for worker 4, got %s", i, got)
+		}
+	}
+
+	random := &feed{rows: rows, mode: "random"}
+	seen := map[string]bool{}
+	for i := range 300 {
+		seen[random.row(i, 0)["n"]] = true
+	}
+	if len(seen) != 3 {
+		t.Errorf("expected every row to be picked at random, got %v", seen)
+	}
+}
+
+func TestTargetSetRequestWithFeed(t *testing.T) {
+	rows := []map[string]string{{"user": "alice"}, {"user": "bob"}}
+	defaults := targetDefaults{
+		method:   "POST",
+		header:   http.Header{"X-User": {"{{.user}}"}},
+		body:     []byte(`{"user": "{{.user}}"}`),
+		template: true,
+	}
+
+	// the URL is checked with a sample row
+	if _, err := newTarget(targetSpec{URL: "http://example.com/users/{{.user}}"}, defaults); err == nil {
+		t.Error("expected error for a URL template without sample variables")
+	}
+	defaults.vars = rows[0]
+	tgt, err := newTarget(targetSpec{URL: "http://example.com/users/{{.user}}"}, defaults)
+	if err != nil {
+		t.Fatalf("newTarget failed: %v", err)
+	}
+	ts := newTargetSet([]*target{tgt})
+	ts.feed = &feed{rows: rows, mode: "sequential"}
+
+	_, req, err := ts.request(t.Context(), newTemplater(0), 1)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if req.URL.Path != "/users/bob" {
+		t.Errorf("expected /users/bob, got %s", req.URL.Path)
+	}
+	if req.Header.Get("X-User") != "bob" {
+		t.Errorf("expected X-User bob, got %q", req.Header.Get("X-User"))
+	}
+	if body, _ := io.ReadAll(req.Body); string(body) != `{"user": "bob"}` {
+		t.Errorf("expected bob in the body, got %s", body)
+	}
+
+	// a row without the variable fails the request
+	ts.feed = &feed{rows: []map[string]string{{"name": "carol"}}, mode: "sequential"}
+	if _, _, err := ts.request(t.Context(), newTemplater(0), 0); err == nil {
+		t.Error("expected error for a missing variable")
+	}
+}
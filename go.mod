@@ -2,4 +2,7 @@ module github.com/sethrylan/boop
 
 go 1.26.0
 
-require github.com/guptarohit/asciigraph v0.10.0
+require (
+	github.com/guptarohit/asciigraph v0.10.0
+	gopkg.in/yaml.v3 v3.0.1
+)
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// harFile is the subset of the HTTP Archive format that is replayed.
+type harFile struct {
+	Log struct {
+		Entries []harEntry `json:"entries"`
+	} `json:"log"`
+}
+
+type harEntry struct {
+	StartedDateTime time.Time  `json:"startedDateTime"`
+	Time            float64    `json:"time"` // total duration, in milliseconds
+	Request         harRequest `json:"request"`
+}
+
+type harRequest struct {
+	Method   string      `json:"method"`
+	URL      string      `json:"url"`
+	Headers  []harRecord `json:"headers"`
+	Cookies  []harRecord `json:"cookies"`
+	PostData *struct {
+		MimeType string `json:"mimeType"`
+		Text     string `json:"text"`
+	} `json:"postData"`
+}
+
+type harRecord struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// harSkipHeaders are recorded headers that are not replayed, since the
+// transport sets them for the connection it actually uses.
+var harSkipHeaders = []string{"Host", "Content-Length", "Connection", "Keep-Alive", "Transfer-Encoding", "Upgrade"}
+
+// harReplay is the request set of a HAR file, in the order the requests
+// were started.
+type harReplay struct {
+	targets []*target
+	offsets []time.Duration // of each request from the first
+	cycle   time.Duration   // from the first request to the end of the last one to finish
+}
+
+// loadHAR reads the entries of a HAR file as targets. Methods, headers,
+// cookies and bodies are replayed as recorded; headers given with -H take
+// precedence over recorded ones.
+func loadHAR(path string, defaults targetDefaults) (*harReplay, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // User explicitly specified file path via -har flag
+	if err != nil {
+		return nil, err
+	}
+	var har harFile
+	if err := json.Unmarshal(data, &har); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	entries := har.Log.Entries
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("%s has no entries", path)
+	}
+	slices.SortStableFunc(entries, func(a, b harEntry) int {
+		return a.StartedDateTime.Compare(b.StartedDateTime)
+	})
+
+	replay := &harReplay{}
+	first := entries[0].StartedDateTime
+	for i, e := range entries {
+		t, err := newTarget(harSpec(e.Request, defaults.header), harDefaults(e.Request, defaults))
+		if err != nil {
+			return nil, fmt.Errorf("%s: entry %d: %w", path, i+1, err)
+		}
+		replay.targets = append(replay.targets, t)
+
+		offset := e.StartedDateTime.Sub(first)
+		replay.offsets = append(replay.offsets, offset)
+		replay.cycle = max(replay.cycle, offset+time.Duration(e.Time*float64(time.Millisecond)))
+	}
+	return replay, nil
+}
+
+// harSpec returns the target of a recorded request. Repeated headers are
+// joined into one, and cookies and the body's MIME type are added as headers
+// if they were not recorded as such.
+func harSpec(r harRequest, flagHeader http.Header) targetSpec {
+	spec := targetSpec{URL: r.URL, Headers: map[string]string{}}
+	for _, h := range r.Headers {
+		key := http.CanonicalHeaderKey(h.Name)
+		if strings.HasPrefix(h.Name, ":") || slices.Contains(harSkipHeaders, key) || flagHeader.Get(key) != "" {
+			continue // HTTP/2 pseudo-headers, connection headers and those overridden by -H
+		}
+		sep := ", "
+		if key == "Cookie" {
+			sep = "; "
+		}
+		if v, ok := spec.Headers[key]; ok {
+			spec.Headers[key] = v + sep + h.Value
+		} else {
+			spec.Headers[key] = h.Value
+		}
+	}
+	if _, ok := spec.Headers["Content-Type"]; !ok && r.PostData != nil && r.PostData.MimeType != "" && flagHeader.Get("Content-Type") == "" {
+		spec.Headers["Content-Type"] = r.PostData.MimeType
+	}
+	if _, ok := spec.Headers["Cookie"]; !ok && len(r.Cookies) > 0 && flagHeader.Get("Cookie") == "" {
+		cookies := make([]string, len(r.Cookies))
+		for i, c := range r.Cookies {
+			cookies[i] = c.Name + "=" + c.Value
+		}
+		spec.Headers["Cookie"] = strings.Join(cookies, "; ")
+	}
+	return spec
+}
+
+// harDefaults returns defaults with the method and body of a recorded request.
+func harDefaults(r harRequest, defaults targetDefaults) targetDefaults {
+	defaults.method = r.Method
+	defaults.body = nil
+	if r.PostData != nil && r.PostData.Text != "" {
+		defaults.body = []byte(r.PostData.Text)
+	}
+	return defaults
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testHAR = `{
+  "log": {
+    "version": "1.2",
+    "entries": [
+      {
+        "startedDateTime": "2024-05-01T10:00:00.250Z",
+        "time": 120,
+        "request": {
+          "method": "POST",
+          "url": "https://example.com/api/cart",
+          "headers": [
+            {"name": ":authority", "value": "example.com"},
+            {"name": "content-length", "value": "11"},
+            {"name": "accept", "value": "application/json"},
+            {"name": "x-trace", "value": "a"},
+            {"name": "x-trace", "value": "b"}
+          ],
+          "cookies": [
+            {"name": "session", "value": "abc"},
+            {"name": "theme", "value": "dark"}
+          ],
+          "postData": {"mimeType": "application/json", "text": "{\"sku\": 42}"}
+        }
+      },
+      {
+        "startedDateTime": "2024-05-01T10:00:00.000Z",
+        "time": 300,
+        "request": {
+          "method": "GET",
+          "url": "https://example.com/",
+          "headers": [
+            {"name": "Cookie", "value": "session=abc"},
+            {"name": "Authorization", "value": "Bearer recorded"}
+          ],
+          "cookies": [{"name": "session", "value": "abc"}]
+        }
+      }
+    ]
+  }
+}`
+
+func TestLoadHAR(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "page.har")
+	if err := os.WriteFile(path, []byte(testHAR), 0644); err != nil {
+		t.Fatalf("failed to create har file: %v", err)
+	}
+
+	header := http.Header{"Authorization": {"Bearer replay"}}
+	replay, err := loadHAR(path, targetDefaults{method: "DELETE", header: header, body: []byte("flag body")})
+	if err != nil {
+		t.Fatalf("loadHAR failed: %v", err)
+	}
+	if len(replay.targets) != 2 {
+		t.Fatalf("expected 2 targets, got %d", len(replay.targets))
+	}
+
+	// entries are replayed in the order they were started
+	page, cart := replay.targets[0].req, replay.targets[1].req
+	if page.Method != http.MethodGet || page.URL.String() != "https://example.com/" || page.Body != nil {
+		t.Errorf("expected GET / without a body first, got %s %s", page.Method, page.URL)
+	}
+	if page.Header.Get("Authorization") != "Bearer replay" || page.Header.Get("Cookie") != "session=abc" {
+		t.Errorf("expected -H to override recorded headers, got %v", page.Header)
+	}
+
+	if cart.Method != http.MethodPost {
+		t.Errorf("expected POST, got %s", cart.Method)
+	}
+	if cart.Header.Get("X-Trace") != "a, b" || cart.Header.Get("Cookie") != "session=abc; theme=dark" {
+		t.Errorf("expected joined headers and cookies, got %v", cart.Header)
+	}
+	if cart.Header.Get("Content-Type") != "application/json" || cart.Header.Get("Content-Length") != "" || cart.Header.Get(":authority") != "" {
+		t.Errorf("unexpected headers %v", cart.Header)
+	}
+	body, _ := cart.GetBody()
+	if b, _ := io.ReadAll(body); string(b) != `{"sku": 42}` {
+		t.Errorf("expected the recorded body, got %q", b)
+	}
+
+	if replay.offsets[0] != 0 || replay.offsets[1] != 250*time.Millisecond {
+		t.Errorf("expected offsets 0 and 250ms, got %v", replay.offsets)
+	}
+	// the first entry takes longest to finish, at 300ms; the second ends at 370ms
+	if replay.cycle != 370*time.Millisecond {
+		t.Errorf("expected a cycle of 370ms, got %s", replay.cycle)
+	}
+}
+
+func TestLoadHARErrors(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"empty.har":   `{"log": {"entries": []}}`,
+		"invalid.har": `{"log": `,
+		"badurl.har":  `{"log": {"entries": [{"startedDateTime": "2024-05-01T10:00:00Z", "request": {"method": "GET", "url": "/relative"}}]}}`,
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to create har file: %v", err)
+		}
+		if _, err := loadHAR(path, targetDefaults{}); err == nil {
+			t.Errorf("expected error for %s", name)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"time"
+)
+
+// histogram records durations in fixed memory, in the style of an HDR
+// histogram: values are counted in buckets whose width grows with the value,
+// so that every value is kept to the configured number of significant
+// decimal digits, from a microsecond up to maxTrackable.
+type histogram struct {
+	subBits  uint // 1<<subBits sub-buckets cover each power of two
+	counts   []int64
+	total    int64
+	sum      time.Duration
+	min, max time.Duration
+}
+
+// maxTrackable is the largest duration a histogram distinguishes; longer
+// durations are counted as maxTrackable, but still count towards the mean
+// and maximum exactly.
+const maxTrackable = (1 << 36) * time.Microsecond // about 19 hours
+
+// newHistogram returns an empty histogram that keeps digits significant
+// decimal digits (1 to 4).
+func newHistogram(digits int) *histogram {
+	digits = min(max(digits, 1), 4)
+	largest := 2 // 2·10^digits sub-buckets give digits of precision
+	for range digits {
+		largest *= 10
+	}
+	subBits := uint(bits.Len(uint(largest - 1)))
+	h := &histogram{subBits: subBits}
+	h.counts = make([]int64, h.index(int64(maxTrackable/time.Microsecond))+1)
+	return h
+}
+
+// index returns the bucket of a value, in microseconds. Values below the
+// sub-bucket count are counted exactly; above it, each power of two is split
+// into half as many sub-buckets, of equal width.
+func (h *histogram) index(v int64) int {
+	subCount := int64(1) << h.subBits
+	if v < subCount {
+		return int(v)
+	}
+	shift := uint(bits.Len64(uint64(v))) - h.subBits
+	half := subCount / 2
+	return int(subCount + int64(shift-1)*half + (v>>shift - half))
+}
+
+// value returns the midpoint of bucket i, in microseconds.
+func (h *histogram) value(i int) int64 {
+	subCount := 1 << h.subBits
+	if i < subCount {
+		return int64(i)
+	}
+	half := subCount / 2
+	shift := uint((i-subCount)/half + 1)
+	lowest := int64((i-subCount)%half+half) << shift
+	return lowest + (int64(1)<<shift)/2
+}
+
+func (h *histogram) record(d time.Duration) {
+	if h.total == 0 || d < h.min {
+		h.min = d
+	}
+	if d > h.max {
+		h.max = d
+	}
+	h.total++
+	h.sum += d
+	h.counts[h.index(int64(min(max(d, 0), maxTrackable)/time.Microsecond))]++
+}
+
+func (h *histogram) clone() *histogram {
+	c := *h
+	c.counts = append([]int64(nil), h.counts...)
+	return &c
+}
+
+func (h *histogram) count() int64 { return h.total }
+
+func (h *histogram) mean() time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+	return h.sum / time.Duration(h.total)
+}
+
+// percentile returns the p-th percentile (0..1), with the same ranking as
+// percentile does for a sorted slice.
+func (h *histogram) percentile(p float64) time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+	rank := min(int64(float64(h.total)*p+.5), h.total-1) + 1
+	var seen int64
+	for i, c := range h.counts {
+		seen += c
+		if seen >= rank {
+			v := time.Duration(h.value(i)) * time.Microsecond
+			return min(max(v, h.min), h.max)
+		}
+	}
+	return h.max
+}
+
+// bins spreads the recorded values over n equal width bins from min to max,
+// returning the width of each bin and their counts.
+func (h *histogram) bins(n int) (time.Duration, []int64) {
+	size := (h.max - h.min) / time.Duration(n-1)
+	if size == 0 {
+		size = 1 * time.Millisecond // prevent division by zero
+	}
+	bins := make([]int64, n)
+	for i, c := range h.counts {
+		if c == 0 {
+			continue
+		}
+		v := min(max(time.Duration(h.value(i))*time.Microsecond, h.min), h.max)
+		bins[min(int((v-h.min)/size), n-1)] += c
+	}
+	return size, bins
+}
+
+// stddev returns the sample standard deviation of the recorded values,
+// estimated from bucket midpoints.
+func (h *histogram) stddev() time.Duration {
+	if h.total < 2 {
+		return 0
+	}
+	mean := float64(h.mean() / time.Microsecond)
+	var sq float64
+	for i, c := range h.counts {
+		if c == 0 {
+			continue
+		}
+		d := float64(h.value(i)) - mean
+		sq += float64(c) * d * d
+	}
+	return time.Duration(math.Sqrt(sq/float64(h.total-1))) * time.Microsecond
+}
+
+// histogramSnapshot is the persisted form of a histogram. Only non-empty
+// buckets are kept, as index and count pairs.
+type histogramSnapshot struct {
+	SubBits uint       `json:"sub_bits"`
+	Total   int64      `json:"total"`
+	Sum     int64      `json:"sum_ns"`
+	Min     int64      `json:"min_ns"`
+	Max     int64      `json:"max_ns"`
+	Buckets [][2]int64 `json:"buckets"`
+}
+
+func (h *histogram) snapshot() *histogramSnapshot {
+	s := &histogramSnapshot{
+		SubBits: h.subBits,
+		Total:   h.total,
+		Sum:     int64(h.sum),
+		Min:     int64(h.min),
+		Max:     int64(h.max),
+		Buckets: [][2]int64{},
+	}
+	for i, c := range h.counts {
+		if c > 0 {
+			s.Buckets = append(s.Buckets, [2]int64{int64(i), c})
+		}
+	}
+	return s
+}
+
+// histogram restores the histogram of a snapshot.
+func (s *histogramSnapshot) histogram() (*histogram, error) {
+	if s.SubBits < 1 || s.SubBits > 16 {
+		return nil, fmt.Errorf("invalid histogram sub-bucket bits %d", s.SubBits)
+	}
+	h := &histogram{
+		subBits: s.SubBits,
+		total:   s.Total,
+		sum:     time.Duration(s.Sum),
+		min:     time.Duration(s.Min),
+		max:     time.Duration(s.Max),
+	}
+	h.counts = make([]int64, h.index(int64(maxTrackable/time.Microsecond))+1)
+	var total int64
+	for _, b := range s.Buckets {
+		if b[0] < 0 || b[0] >= int64(len(h.counts)) || b[1] < 0 {
+			return nil, fmt.Errorf("invalid histogram bucket %v", b)
+		}
+		h.counts[b[0]] += b[1]
+		total += b[1]
+	}
+	if total != h.total {
+		return nil, fmt.Errorf("histogram buckets hold %d values, expected %d", total, h.total)
+	}
+	return h, nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// within reports whether got is within the relative error of want.
+func within(got, want time.Duration, relErr float64) bool {
+	diff := float64(got - want)
+	return diff <= float64(want)*relErr && -diff <= float64(want)*relErr
+}
+
+func TestHistogramPrecision(t *testing.T) {
+	for _, digits := range []int{1, 2, 3, 4} {
+		h := newHistogram(digits)
+		relErr := 1.0
+		for range digits {
+			relErr /= 10
+		}
+		for _, d := range []time.Duration{
+			1500 * time.Microsecond,
+			37 * time.Millisecond,
+			123456 * time.Microsecond,
+			12 * time.Second,
+			15 * time.Minute,
+		} {
+			h = newHistogram(digits)
+			h.record(d)
+			h.record(2 * d) // so the percentile is not clamped to min and max
+			if got := h.percentile(0); !within(got, d, relErr) {
+				t.Errorf("digits %d: expected %s within %v, got %s", digits, d, relErr, got)
+			}
+		}
+	}
+}
+
+func TestHistogramStats(t *testing.T) {
+	h := newHistogram(3)
+	if h.percentile(0.5) != 0 || h.mean() != 0 {
+		t.Error("expected zero percentile and mean for empty histogram")
+	}
+
+	// 1ms .. 100ms
+	for i := 1; i <= 100; i++ {
+		h.record(time.Duration(i) * time.Millisecond)
+	}
+
+	if h.count() != 100 {
+		t.Errorf("expected count 100, got %d", h.count())
+	}
+	if h.min != time.Millisecond || h.max != 100*time.Millisecond {
+		t.Errorf("expected min 1ms and max 100ms, got %s and %s", h.min, h.max)
+	}
+	if h.mean() != 50500*time.Microsecond {
+		t.Errorf("expected mean 50.5ms, got %s", h.mean())
+	}
+	tests := []struct {
+		p    float64
+		want time.Duration
+	}{
+		{0, time.Millisecond},
+		{0.5, 51 * time.Millisecond},
+		{0.99, 100 * time.Millisecond},
+		{1, 100 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		if got := h.percentile(tt.p); !within(got, tt.want, 0.001) {
+			t.Errorf("percentile(%v) = %s, want %s", tt.p, got, tt.want)
+		}
+	}
+
+	// Test bins
+	size, bins := h.bins(11)
+	if size != 9900*time.Microsecond {
+		t.Errorf("expected bin size 9.9ms, got %s", size)
+	}
+	var total int64
+	for _, c := range bins {
+		total += c
+	}
+	if total != 100 || bins[0] != 10 || bins[10] != 1 {
+		t.Errorf("unexpected bins %v", bins)
+	}
+}
+
+func TestHistogramCloneAndClamp(t *testing.T) {
+	a := newHistogram(2)
+	a.record(time.Millisecond)
+
+	c := a.clone()
+	c.record(time.Second)
+	c.record(100 * time.Hour) // beyond maxTrackable
+	if c.count() != 3 || c.min != time.Millisecond || c.max != 100*time.Hour {
+		t.Errorf("unexpected clone result: count %d, min %s, max %s", c.count(), c.min, c.max)
+	}
+	if a.count() != 1 || a.max != time.Millisecond {
+		t.Error("recording into a clone must not modify the original")
+	}
+	if got := c.percentile(0.4); !within(got, time.Second, 0.01) {
+		t.Errorf("expected 40th percentile of 1s, got %s", got)
+	}
+	if got := c.percentile(0.99); got < maxTrackable-maxTrackable/100 {
+		t.Errorf("expected untrackable value to be counted near maxTrackable, got %s", got)
+	}
+}
+
+func TestHistogramFixedMemory(t *testing.T) {
+	h := newHistogram(3)
+	size := len(h.counts)
+	for i := range 100000 {
+		h.record(time.Duration(i) * time.Microsecond * 37)
+	}
+	if len(h.counts) != size {
+		t.Errorf("expected histogram to stay at %d buckets, got %d", size, len(h.counts))
+	}
+}
+
+func TestHistogramSnapshot(t *testing.T) {
+	h := newHistogram(2)
+	for _, d := range []time.Duration{3 * time.Millisecond, 40 * time.Millisecond, 40 * time.Millisecond, 2 * time.Second} {
+		h.record(d)
+	}
+	s := h.snapshot()
+	if len(s.Buckets) != 3 {
+		t.Errorf("expected 3 non-empty buckets, got %d", len(s.Buckets))
+	}
+
+	r, err := s.histogram()
+	if err != nil {
+		t.Fatalf("histogram failed: %v", err)
+	}
+	if r.count() != h.count() || r.mean() != h.mean() || r.min != h.min || r.max != h.max {
+		t.Errorf("expected restored stats to match, got count %d mean %s min %s max %s", r.count(), r.mean(), r.min, r.max)
+	}
+	for _, p := range []float64{0, 0.5, 0.9, 1} {
+		if r.percentile(p) != h.percentile(p) {
+			t.Errorf("expected p%v %s, got %s", p, h.percentile(p), r.percentile(p))
+		}
+	}
+
+	s.Total++
+	if _, err := s.histogram(); err == nil {
+		t.Error("expected error for a snapshot whose buckets don't add up")
+	}
+}
+
+func TestHistogramStddev(t *testing.T) {
+	h := newHistogram(3)
+	if h.stddev() != 0 {
+		t.Errorf("expected 0 for an empty histogram, got %s", h.stddev())
+	}
+	for _, d := range []time.Duration{10, 20, 30, 40} {
+		h.record(d * time.Millisecond)
+	}
+	// sample standard deviation of 10, 20, 30, 40 is 12.91ms
+	if got := h.stddev(); !within(got, 12910*time.Microsecond, 0.01) {
+		t.Errorf("expected about 12.91ms, got %s", got)
+	}
+}
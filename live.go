@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"fmt"
+	"maps"
 	"sync"
 	"time"
 
@@ -20,10 +21,15 @@ type liveMetrics struct {
 
 	points      []timeSeriesPoint
 	lastCount   int
+	lastOK      int64         // successful requests at the last sample
+	lastSum     time.Duration // their total latency
 	lastTime    time.Time
 	startTime   time.Time
 	windowSize  time.Duration
 	statusCount map[int]int
+	errors      map[string]errorCount
+	stageText   string
+	targetText  string
 }
 
 func newLiveMetrics(windowSize time.Duration) *liveMetrics {
@@ -39,18 +45,41 @@ func newLiveMetrics(windowSize time.Duration) *liveMetrics {
 func (lm *liveMetrics) sample(results *resultSet) {
 	// Snapshot results under results.mu only (avoid nested locks)
 	results.mu.Lock()
-	records := results.records
-	currentCount := len(records)
+	var currentCount int
+	var currentOK int64
+	var currentSum time.Duration
 	statusCount := map[int]int{}
-	for _, rec := range records {
-		statusCount[rec.status]++
+	var errs map[string]errorCount
+	if results.total != nil {
+		currentCount = results.total.count
+		currentOK = results.total.latency.count()
+		currentSum = results.total.latency.sum
+		statusCount = maps.Clone(results.total.statusCount)
+		errs = maps.Clone(results.total.errors)
 	}
+	// the reports scan every histogram, so they are computed from copies
+	// rather than holding up workers; -search adds stages as it goes
+	stages := results.stages
+	byStage := snapshotStats(results.byStage)
+	byTarget := snapshotStats(results.byTarget)
 	results.mu.Unlock()
 
+	stageText := ""
+	if len(stages) > 0 {
+		stageText = stageDistribution(stageReports(stages, byStage))
+	}
+	targetText := ""
+	if len(results.targets) > 0 {
+		targetText = targetDistribution(targetReports(results.targets, byTarget, time.Since(results.start).Seconds()))
+	}
+
 	lm.Lock()
 	defer lm.Unlock()
 
 	lm.statusCount = statusCount
+	lm.errors = errs
+	lm.stageText = stageText
+	lm.targetText = targetText
 
 	now := time.Now()
 
@@ -63,18 +92,9 @@ func (lm *liveMetrics) sample(results *resultSet) {
 	}
 
 	// Calculate average latency for new records
-	var totalLatency time.Duration
-	newRecords := 0
-	for i := lm.lastCount; i < currentCount; i++ {
-		if !records[i].failed {
-			totalLatency += records[i].latency
-			newRecords++
-		}
-	}
-
 	avgLatency := 0.0
-	if newRecords > 0 {
-		avgLatency = totalLatency.Seconds() / float64(newRecords)
+	if newRecords := currentOK - lm.lastOK; newRecords > 0 {
+		avgLatency = (currentSum - lm.lastSum).Seconds() / float64(newRecords)
 	}
 
 	// Add the data point
@@ -86,6 +106,8 @@ func (lm *liveMetrics) sample(results *resultSet) {
 
 	// Update tracking values
 	lm.lastCount = currentCount
+	lm.lastOK = currentOK
+	lm.lastSum = currentSum
 	lm.lastTime = now
 
 	// Trim old points outside window
@@ -101,6 +123,17 @@ func (lm *liveMetrics) sample(results *resultSet) {
 	}
 }
 
+// snapshotStats copies what the stage and target reports use of each stats.
+func snapshotStats(all []*stats) []*stats {
+	snap := make([]*stats, len(all))
+	for i, s := range all {
+		if s != nil {
+			snap[i] = &stats{count: s.count, failed: s.failed, latency: s.latency.clone(), statusCount: maps.Clone(s.statusCount)}
+		}
+	}
+	return snap
+}
+
 func (lm *liveMetrics) renderGraphs() string {
 	lm.Lock()
 	defer lm.Unlock()
@@ -141,8 +174,13 @@ func (lm *liveMetrics) renderGraphs() string {
 
 	elapsedTime := time.Since(lm.startTime).Round(time.Second)
 
+	errorText := ""
+	if len(lm.errors) > 0 {
+		errorText = errorDistribution(lm.errors)
+	}
+
 	// Combine graphs with headers
-	return fmt.Sprintf("\033[H\033[2J(running for %s, showing %s)\n\n%s\n\n%s\n\n%s", elapsedTime, min(lm.windowSize, elapsedTime), latencyGraph, rpsGraph, statusCodeDistribution(lm.statusCount))
+	return fmt.Sprintf("\033[H\033[2J(running for %s, showing %s)\n\n%s\n\n%s\n%s%s\n%s%s", elapsedTime, min(lm.windowSize, elapsedTime), latencyGraph, rpsGraph, lm.stageText, lm.targetText, statusCodeDistribution(lm.statusCount), errorText)
 }
 
 func startLiveMonitor(ctx context.Context, results *resultSet) {
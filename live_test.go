@@ -0,0 +1,35 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestLiveSampleDuringSearch tests that sampling does not race with a search
+// adding stages, under the race detector.
+func TestLiveSampleDuringSearch(t *testing.T) {
+	results := &resultSet{start: time.Now(), targets: []string{"a", "b"}}
+	lm := newLiveMetrics(time.Minute)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := range 50 {
+			results.mu.Lock()
+			results.stages = append(results.stages, stage{target: 10}, stage{duration: time.Second, target: 10})
+			results.mu.Unlock()
+			results.add(record{latency: time.Millisecond, status: 200, stage: len(results.stages) - 1, target: i % 2})
+		}
+	}()
+	for range 50 {
+		lm.sample(results)
+	}
+	wg.Wait()
+
+	lm.sample(results)
+	if lm.stageText == "" || lm.targetText == "" {
+		t.Errorf("expected stage and target reports, got %q and %q", lm.stageText, lm.targetText)
+	}
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"strings"
+	"time"
+)
+
+// report is the statistics of a run. It is computed once from a resultSet
+// and then rendered as text or JSON, so both show the same numbers. Durations
+// are in seconds.
+type report struct {
+	Config          *runConfig            `json:"config,omitempty"`
+	Duration        float64               `json:"duration_secs"`
+	Requests        int                   `json:"requests"`
+	Successful      int64                 `json:"successful"`
+	Failed          int                   `json:"failed"`
+	RequestsPerSec  float64               `json:"requests_per_sec"`
+	ErrorRate       float64               `json:"error_rate_percent"`
+	TotalBytes      int64                 `json:"total_bytes"`
+	BytesPerRequest int64                 `json:"bytes_per_request"`
+	Latency         *latencyReport        `json:"latency,omitempty"`        // service time
+	ResponseTime    *latencyReport        `json:"response_time,omitempty"`  // from the intended send time, if paced
+	FailedLatency   *latencyReport        `json:"failed_latency,omitempty"` // service time of failed requests that got a response
+	Histogram       []histogramBucket     `json:"histogram,omitempty"`
+	Phases          []phaseReport         `json:"phases,omitempty"`
+	TLS             *tlsReport            `json:"tls,omitempty"`
+	StatusCodes     map[int]int           `json:"status_codes"`
+	Errors          map[string]errorCount `json:"errors,omitempty"`
+	CheckFailures   map[string]int        `json:"check_failures,omitempty"`
+	Stages          []stageReport         `json:"stages,omitempty"`
+	Targets         []targetReport        `json:"targets,omitempty"`
+	Search          *searchReport         `json:"search,omitempty"`
+	Comparison      *comparison           `json:"comparison,omitempty"`
+	Thresholds      []thresholdResult     `json:"thresholds,omitempty"`
+}
+
+// runConfig is the configuration of a run, as reported alongside its results.
+type runConfig struct {
+	URL             string   `json:"url,omitempty"`
+	Method          string   `json:"method,omitempty"`
+	Targets         []string `json:"targets,omitempty"`
+	Concurrency     int      `json:"concurrency"`
+	MaxConcurrency  int      `json:"max_concurrency,omitempty"`
+	Requests        int      `json:"requests,omitempty"`
+	Duration        float64  `json:"duration_secs,omitempty"`
+	WorkerRPS       float64  `json:"worker_rps,omitempty"`
+	Rate            float64  `json:"rate,omitempty"`
+	Stages          string   `json:"stages,omitempty"`
+	Search          bool     `json:"search,omitempty"`
+	Feed            string   `json:"feed,omitempty"`
+	HAR             string   `json:"har,omitempty"`
+	Replay          string   `json:"replay,omitempty"`
+	Scenario        string   `json:"scenario,omitempty"`
+	Timeout         float64  `json:"timeout_secs"`
+	HTTP2           bool     `json:"http2"`
+	KeepAlive       bool     `json:"keepalive"`
+	FollowRedirects bool     `json:"follow_redirects"`
+	Insecure        bool     `json:"insecure"`
+	ClientCert      string   `json:"client_cert,omitempty"`
+	CACert          string   `json:"cacert,omitempty"`
+	TLSMinVersion   string   `json:"tls_min_version,omitempty"`
+	TLSMaxVersion   string   `json:"tls_max_version,omitempty"`
+	Ciphers         string   `json:"ciphers,omitempty"`
+	ServerName      string   `json:"server_name,omitempty"`
+	TLSResume       bool     `json:"tls_resume,omitempty"`
+	CookieJar       bool     `json:"cookie_jar,omitempty"`
+	Isolated        bool     `json:"isolated,omitempty"`
+	Resolve         []string `json:"resolve,omitempty"`
+	ConnectTo       []string `json:"connect_to,omitempty"`
+}
+
+type latencyReport struct {
+	Count int64   `json:"count"`
+	Mean  float64 `json:"mean"`
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	P10   float64 `json:"p10"`
+	P25   float64 `json:"p25"`
+	P50   float64 `json:"p50"`
+	P75   float64 `json:"p75"`
+	P90   float64 `json:"p90"`
+	P95   float64 `json:"p95"`
+	P99   float64 `json:"p99"`
+}
+
+func newLatencyReport(h *histogram) *latencyReport {
+	pct := func(p float64) float64 { return h.percentile(p).Seconds() }
+	return &latencyReport{
+		Count: h.count(),
+		Mean:  h.mean().Seconds(),
+		Min:   h.min.Seconds(),
+		Max:   h.max.Seconds(),
+		P10:   pct(0.10),
+		P25:   pct(0.25),
+		P50:   pct(0.50),
+		P75:   pct(0.75),
+		P90:   pct(0.90),
+		P95:   pct(0.95),
+		P99:   pct(0.99),
+	}
+}
+
+type histogramBucket struct {
+	Start float64 `json:"start"`
+	Count int64   `json:"count"`
+}
+
+type phaseReport struct {
+	Name string `json:"name"`
+	latencyReport
+}
+
+// report computes the statistics of the result set.
+func (r *resultSet) report() *report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rep := &report{
+		Duration:    r.end.Sub(r.start).Seconds(),
+		StatusCodes: map[int]int{},
+	}
+	if r.total == nil {
+		return rep
+	}
+	rep.Requests = r.total.count
+	rep.Failed = r.total.failed
+	rep.Successful = r.total.latency.count()
+	rep.RequestsPerSec = float64(rep.Requests) / rep.Duration
+	rep.ErrorRate = 100 * float64(rep.Failed) / float64(rep.Requests)
+	rep.TotalBytes = r.total.bytes
+	rep.StatusCodes = maps.Clone(r.total.statusCount)
+	if len(r.total.errors) > 0 {
+		rep.Errors = maps.Clone(r.total.errors)
+	}
+	if len(r.total.checks) > 0 {
+		rep.CheckFailures = maps.Clone(r.total.checks)
+	}
+	if r.total.failedLatency.count() > 0 {
+		rep.FailedLatency = newLatencyReport(r.total.failedLatency)
+	}
+	if rep.Successful == 0 {
+		return rep
+	}
+	rep.BytesPerRequest = r.total.bytes / rep.Successful
+
+	rep.Latency = newLatencyReport(r.total.latency)
+	if r.paced {
+		rep.ResponseTime = newLatencyReport(r.total.response)
+	}
+
+	// Calculate histogram bins
+	histoBins := 11
+	binSize, bins := r.total.latency.bins(histoBins)
+	for i, count := range bins {
+		binTime := r.total.latency.min + time.Duration(i)*binSize
+		rep.Histogram = append(rep.Histogram, histogramBucket{Start: binTime.Seconds(), Count: count})
+	}
+
+	for i, h := range r.phases {
+		if h.count() > 0 {
+			rep.Phases = append(rep.Phases, phaseReport{Name: phaseNames[i], latencyReport: *newLatencyReport(h)})
+		}
+	}
+
+	rep.TLS = r.tls.report(r.total.latency)
+
+	if len(r.stages) > 0 {
+		rep.Stages = stageReports(r.stages, r.byStage)
+	}
+	if len(r.targets) > 0 {
+		rep.Targets = targetReports(r.targets, r.byTarget, rep.Duration)
+	}
+	return rep
+}
+
+// writeJSON renders the report as a single JSON document.
+func (rep *report) writeJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rep)
+}
+
+// writeText renders the report for humans.
+func (rep *report) writeText(w io.Writer) {
+	if rep.Requests == 0 {
+		fmt.Fprintln(w, "No records, something went wrong.")
+		return
+	}
+	if rep.Successful == 0 {
+		fmt.Fprintln(w, "All requests failed, cannot provide summary.")
+		fmt.Fprintf(w, "\n  Error rate:   %s\n", errorRate(rep))
+		if rep.FailedLatency != nil {
+			fmt.Fprintf(w, "\nFailed response latency distribution:\n")
+			fmt.Fprint(w, distribution(rep.FailedLatency))
+		}
+		fmt.Fprint(w, statusCodeDistribution(rep.StatusCodes))
+		fmt.Fprint(w, errorDistribution(rep.Errors))
+		if len(rep.CheckFailures) > 0 {
+			fmt.Fprint(w, checkDistribution(rep.CheckFailures))
+		}
+		if rep.Search != nil {
+			fmt.Fprint(w, searchSummary(rep.Search))
+		}
+		if rep.Comparison != nil {
+			fmt.Fprint(w, comparisonTable(rep.Comparison))
+		}
+		if len(rep.Thresholds) > 0 {
+			fmt.Fprint(w, thresholdTable(rep.Thresholds))
+		}
+		return
+	}
+	lat := rep.Latency
+
+	// Print summary
+	fmt.Fprintf(w, "\nSummary:\n")
+	fmt.Fprintf(w, "  Total:        %.4f secs\n", rep.Duration)
+	fmt.Fprintf(w, "  Slowest:      %.4f secs\n", lat.Max)
+	fmt.Fprintf(w, "  Fastest:      %.4f secs\n", lat.Min)
+	fmt.Fprintf(w, "  Average:      %.4f secs\n", lat.Mean)
+	fmt.Fprintf(w, "  Requests/sec: %.4f\n", rep.RequestsPerSec)
+	fmt.Fprintf(w, "  Error rate:   %s\n", errorRate(rep))
+	fmt.Fprintf(w, "\n")
+	fmt.Fprintf(w, "  Total data:   %d bytes\n", rep.TotalBytes)
+	fmt.Fprintf(w, "  Size/request: %d bytes\n", rep.BytesPerRequest)
+
+	// Find the max count for scaling histogram bars
+	maxCount := int64(0)
+	for _, b := range rep.Histogram {
+		if b.Count > maxCount {
+			maxCount = b.Count
+		}
+	}
+
+	// Print histogram
+	fmt.Fprintf(w, "\nResponse time histogram:\n")
+	for _, b := range rep.Histogram {
+		bar := ""
+		if maxCount > 0 {
+			barLength := int(40 * float64(b.Count) / float64(maxCount))
+			bar = strings.Repeat("■", barLength)
+		}
+		fmt.Fprintf(w, "  %.3f [%d]\t|%s\n", b.Start, b.Count, bar)
+	}
+	fmt.Fprintf(w, "\n\n")
+
+	// Print latency distribution
+	fmt.Fprintf(w, "Latency distribution:\n")
+	fmt.Fprint(w, distribution(lat))
+
+	// With a rate schedule, service time hides the time requests spent
+	// waiting to be sent (coordinated omission), so also report response time
+	// measured from when each request should have been sent.
+	if rep.ResponseTime != nil {
+		fmt.Fprintf(w, "\nResponse time distribution (from intended send time):\n")
+		fmt.Fprint(w, distribution(rep.ResponseTime))
+	}
+
+	// Failed responses are left out of the distributions above, so that a
+	// server failing fast doesn't look fast
+	if rep.FailedLatency != nil {
+		fmt.Fprintf(w, "\nFailed response latency distribution:\n")
+		fmt.Fprint(w, distribution(rep.FailedLatency))
+	}
+
+	// Print the timing of each phase of a request; dialing phases only
+	// include requests that made a new connection
+	fmt.Fprintf(w, "\nDetails (average, fastest, slowest, 50%%, 90%%, 99%%):\n")
+	for _, ph := range rep.Phases {
+		fmt.Fprintf(w, "  %-14s %.4f, %.4f, %.4f, %.4f, %.4f, %.4f secs\n", ph.Name+":",
+			ph.Mean, ph.Min, ph.Max, ph.P50, ph.P90, ph.P99)
+	}
+
+	if rep.TLS != nil {
+		fmt.Fprint(w, tlsSummary(rep.TLS))
+	}
+
+	// Print per-stage metrics
+	if len(rep.Stages) > 0 {
+		fmt.Fprint(w, stageDistribution(rep.Stages))
+	}
+
+	// Print per-target metrics
+	if len(rep.Targets) > 0 {
+		fmt.Fprint(w, targetDistribution(rep.Targets))
+	}
+
+	// Print status code distribution
+	fmt.Fprint(w, statusCodeDistribution(rep.StatusCodes))
+
+	// Print error distribution
+	if len(rep.Errors) > 0 {
+		fmt.Fprint(w, errorDistribution(rep.Errors))
+	}
+	if len(rep.CheckFailures) > 0 {
+		fmt.Fprint(w, checkDistribution(rep.CheckFailures))
+	}
+
+	if rep.Search != nil {
+		fmt.Fprint(w, searchSummary(rep.Search))
+	}
+
+	if rep.Comparison != nil {
+		fmt.Fprint(w, comparisonTable(rep.Comparison))
+	}
+
+	if len(rep.Thresholds) > 0 {
+		fmt.Fprint(w, thresholdTable(rep.Thresholds))
+	}
+}
+
+// errorRate formats the share of requests that failed.
+func errorRate(rep *report) string {
+	return fmt.Sprintf("%.2f%% (%d of %d)", rep.ErrorRate, rep.Failed, rep.Requests)
+}
+
+// distribution formats the standard set of percentiles of a latency report.
+func distribution(l *latencyReport) string {
+	var sb strings.Builder
+	for _, p := range []struct {
+		pct  int
+		secs float64
+	}{{10, l.P10}, {25, l.P25}, {50, l.P50}, {75, l.P75}, {90, l.P90}, {95, l.P95}, {99, l.P99}} {
+		fmt.Fprintf(&sb, "  %d%% in %.4f secs\n", p.pct, p.secs)
+	}
+	return sb.String()
+}
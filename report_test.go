@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReportText(t *testing.T) {
+	// This is primarily a visual output function, so we'll verify it doesn't crash with test data
+	// and basic output validation
+
+	rs := &resultSet{
+		start: time.Now().Add(-1 * time.Second),
+		end:   time.Now(),
+	}
+	phases := [numPhases]time.Duration{phaseTTFB: 80 * time.Millisecond, phaseTransfer: 20 * time.Millisecond}
+	for _, rec := range []record{
+		{latency: 100 * time.Millisecond, status: 200, size: 100, failed: false, phases: phases},
+		{latency: 150 * time.Millisecond, status: 200, size: 150, failed: false},
+		{latency: 200 * time.Millisecond, status: 200, size: 200, failed: false},
+		{latency: 0, status: 0, size: 0, failed: true, errMsg: "timeout"},
+		{latency: 300 * time.Millisecond, status: 404, size: 50, failed: false},
+	} {
+		rs.add(rec)
+	}
+
+	var output bytes.Buffer
+	rs.report().writeText(&output)
+	outputStr := output.String()
+
+	// Verify basic output components
+	if !strings.Contains(outputStr, "Summary:") {
+		t.Error("Missing 'Summary:' in output")
+	}
+
+	if !strings.Contains(outputStr, "Latency distribution:") {
+		t.Error("Missing 'Latency distribution:' in output")
+	}
+
+	if !strings.Contains(outputStr, "Status code distribution:") {
+		t.Error("Missing 'Status code distribution:' in output")
+	}
+
+	if !strings.Contains(outputStr, "[200]") || !strings.Contains(outputStr, "[404]") {
+		t.Error("Missing status code counts in output")
+	}
+
+	// only phases that happened are listed
+	if !strings.Contains(outputStr, "TTFB:          0.0800, 0.0800, 0.0800") {
+		t.Errorf("Missing TTFB details in output:\n%s", outputStr)
+	}
+	if strings.Contains(outputStr, "DNS lookup:") {
+		t.Error("Unexpected DNS lookup details in output")
+	}
+}
+
+func TestReportTextPaced(t *testing.T) {
+	rs := &resultSet{
+		start: time.Now().Add(-1 * time.Second),
+		end:   time.Now(),
+		paced: true,
+	}
+	rs.add(record{latency: 100 * time.Millisecond, status: 200})
+	rs.add(record{latency: 100 * time.Millisecond, wait: 2 * time.Second, status: 200})
+
+	var output bytes.Buffer
+	rs.report().writeText(&output)
+	outputStr := output.String()
+
+	if !strings.Contains(outputStr, "Response time distribution") {
+		t.Error("Missing 'Response time distribution' in output")
+	}
+	// the late request's response time includes its wait
+	if !strings.Contains(outputStr, "99% in 2.1000 secs") {
+		t.Errorf("expected corrected p99 of 2.1 secs in output:\n%s", outputStr)
+	}
+}
+
+func TestReportTextAllFailed(t *testing.T) {
+	rs := &resultSet{start: time.Now().Add(-1 * time.Second), end: time.Now()}
+	rs.add(record{failed: true, errMsg: "dial tcp: connection refused", errCategory: "connection refused"})
+
+	var output bytes.Buffer
+	rs.report().writeText(&output)
+	outputStr := output.String()
+
+	if !strings.Contains(outputStr, "All requests failed") {
+		t.Error("Missing 'All requests failed' in output")
+	}
+	if !strings.Contains(outputStr, "[1] connection refused") {
+		t.Errorf("Missing error distribution in output:\n%s", outputStr)
+	}
+}
+
+func TestReportJSON(t *testing.T) {
+	rs := &resultSet{
+		start:  time.Now().Add(-2 * time.Second),
+		end:    time.Now(),
+		paced:  true,
+		stages: []stage{{time.Second, 10}},
+	}
+	rs.add(record{latency: 100 * time.Millisecond, wait: 50 * time.Millisecond, status: 200, size: 10,
+		phases: [numPhases]time.Duration{phaseTTFB: 90 * time.Millisecond}})
+	rs.add(record{latency: 300 * time.Millisecond, status: 200, size: 30})
+	rs.add(record{failed: true, errMsg: "i/o timeout", errCategory: "timeout"})
+
+	rep := rs.report()
+	rep.Config = &runConfig{URL: "http://example.com", Method: "GET", Concurrency: 1}
+
+	var output bytes.Buffer
+	if err := rep.writeJSON(&output); err != nil {
+		t.Fatalf("writeJSON failed: %v", err)
+	}
+
+	var doc map[string]any
+	if err := json.Unmarshal(output.Bytes(), &doc); err != nil {
+		t.Fatalf("output is not valid JSON: %v\n%s", err, output.String())
+	}
+
+	for _, key := range []string{"config", "duration_secs", "requests", "requests_per_sec", "latency",
+		"response_time", "histogram", "phases", "status_codes", "errors", "stages"} {
+		if _, ok := doc[key]; !ok {
+			t.Errorf("missing %q in JSON output", key)
+		}
+	}
+	if doc["requests"] != 3.0 || doc["failed"] != 1.0 || doc["successful"] != 2.0 {
+		t.Errorf("unexpected totals in %s", output.String())
+	}
+	if status := doc["status_codes"].(map[string]any); status["200"] != 2.0 {
+		t.Errorf("expected 2 responses with status 200, got %v", status)
+	}
+	if errs := doc["errors"].(map[string]any); errs["timeout"] == nil {
+		t.Errorf("expected timeout errors, got %v", errs)
+	}
+
+	// the text renderer reports the same numbers
+	if p99 := doc["latency"].(map[string]any)["p99"].(float64); !within(time.Duration(p99*float64(time.Second)), 300*time.Millisecond, 0.001) {
+		t.Errorf("expected latency p99 of 0.3, got %v", p99)
+	}
+	if p99 := doc["response_time"].(map[string]any)["p99"].(float64); !within(time.Duration(p99*float64(time.Second)), 300*time.Millisecond, 0.001) {
+		t.Errorf("expected response time p99 of 0.3, got %v", p99)
+	}
+	phases := doc["phases"].([]any)
+	if len(phases) != 1 || phases[0].(map[string]any)["name"] != "TTFB" {
+		t.Errorf("expected only TTFB phase, got %v", phases)
+	}
+}
+
+func TestReportFailedLatency(t *testing.T) {
+	rs := &resultSet{
+		start: time.Now().Add(-1 * time.Second),
+		end:   time.Now(),
+	}
+	rs.add(record{latency: 100 * time.Millisecond, status: 200})
+	rs.add(record{latency: 2 * time.Millisecond, status: 503, failed: true, errMsg: "unsuccessful status 503 Service Unavailable", errCategory: "5xx status"})
+	rs.add(record{latency: 4 * time.Millisecond, status: 429, failed: true, errMsg: "unsuccessful status 429 Too Many Requests", errCategory: "4xx status"})
+	rs.add(record{latency: time.Second, failed: true, errMsg: "timeout", errCategory: "timeout"})
+
+	rep := rs.report()
+	if rep.ErrorRate != 75 {
+		t.Errorf("expected error rate 75%%, got %v", rep.ErrorRate)
+	}
+	// fast failures are kept out of the latency of successful requests
+	if rep.Latency.Min != 0.1 {
+		t.Errorf("expected min latency 0.1 secs, got %v", rep.Latency.Min)
+	}
+	// and requests without a response out of the failed latency
+	if rep.FailedLatency == nil || rep.FailedLatency.Count != 2 || rep.FailedLatency.Max > 0.005 {
+		t.Fatalf("expected the failed latency of 2 responses under 5ms, got %+v", rep.FailedLatency)
+	}
+
+	var output bytes.Buffer
+	rep.writeText(&output)
+	outputStr := output.String()
+	if !strings.Contains(outputStr, "Error rate:   75.00% (3 of 4)") {
+		t.Errorf("Missing error rate in output:\n%s", outputStr)
+	}
+	if !strings.Contains(outputStr, "Failed response latency distribution:") {
+		t.Errorf("Missing failed latency distribution in output:\n%s", outputStr)
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// scenario is a user journey: a sequence of steps that a worker, as a
+// virtual user, sends in order for each job. Variables captured from the
+// responses of earlier steps are available to the templates of later ones.
+type scenario struct {
+	vars  map[string]string // initial variables of each run
+	steps []*step
+	feed  *feed // variables of each run, if any, over vars
+}
+
+// step is a request of a scenario, and the variables it captures.
+type step struct {
+	target    *target
+	extract   []*extractor
+	needsBody bool
+}
+
+// scenarioSpec is a scenario as written in a YAML file.
+type scenarioSpec struct {
+	Variables map[string]string `yaml:"variables"`
+	Steps     []stepSpec        `yaml:"steps"`
+}
+
+// stepSpec is a step of a scenario file. Like a line of a targets file,
+// fields that are left out default to the -m, -H and -d flags.
+type stepSpec struct {
+	Name    string                 `yaml:"name"`
+	Method  string                 `yaml:"method"`
+	URL     string                 `yaml:"url"`
+	Headers map[string]string      `yaml:"headers"`
+	Body    string                 `yaml:"body"`    // @file reads a file, like -d
+	Extract map[string]extractSpec `yaml:"extract"` // by variable name
+	Check   []string               `yaml:"check"`   // as -check, in addition to those flags
+}
+
+// loadScenario reads a scenario file. The URL, headers and body of every
+// step are templates, see requestTemplate.
+func loadScenario(path string, defaults targetDefaults) (*scenario, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // User explicitly specified file path via -scenario flag
+	if err != nil {
+		return nil, err
+	}
+	var spec scenarioSpec
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&spec); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(spec.Steps) == 0 {
+		return nil, fmt.Errorf("%s has no steps", path)
+	}
+
+	// templates are checked with every variable a step could use, with
+	// placeholders for those that are captured during the run
+	defaults.template = true
+	vars := map[string]string{}
+	for _, s := range spec.Steps {
+		for name := range s.Extract {
+			vars[name] = "x"
+		}
+	}
+	maps.Copy(vars, spec.Variables)
+	maps.Copy(vars, defaults.vars)
+	defaults.vars = vars
+
+	sc := &scenario{vars: spec.Variables}
+	for i, s := range spec.Steps {
+		t, err := newTarget(targetSpec{Name: s.Name, Method: s.Method, URL: s.URL, Headers: s.Headers, Body: s.Body}, defaults)
+		if err != nil {
+			return nil, fmt.Errorf("%s: step %d: %w", path, i+1, err)
+		}
+		st := &step{target: t}
+		for _, expr := range s.Check {
+			c, err := parseCheck(expr)
+			if err != nil {
+				return nil, fmt.Errorf("%s: step %d: %w", path, i+1, err)
+			}
+			t.checks = append(slices.Clip(t.checks), c) // not to share the array of -check
+		}
+		st.needsBody = checksNeedBody(t.checks)
+		for _, name := range slices.Sorted(maps.Keys(s.Extract)) {
+			x, err := newExtractor(name, s.Extract[name])
+			if err != nil {
+				return nil, fmt.Errorf("%s: step %d: %w", path, i+1, err)
+			}
+			st.extract = append(st.extract, x)
+			st.needsBody = st.needsBody || x.needsBody()
+		}
+		sc.steps = append(sc.steps, st)
+	}
+	return sc, nil
+}
+
+// targets returns the targets of the steps, in order.
+func (sc *scenario) targets() []*target {
+	targets := make([]*target, len(sc.steps))
+	for i, s := range sc.steps {
+		targets[i] = s.target
+	}
+	return targets
+}
+
+// run sends the steps of the scenario for job j, as worker, recording each
+// step's request as a request to the target of the same index. A step that
+// fails, fails a check, or whose variables cannot be captured ends the run,
+// since later steps depend on it.
+func (sc *scenario) run(ctx context.Context, client *http.Client, tm *templater, j job, intended time.Time, worker int, out recorder, withTrace bool) {
+	vars := maps.Clone(sc.vars)
+	if vars == nil {
+		vars = map[string]string{}
+	}
+	if sc.feed != nil {
+		maps.Copy(vars, sc.feed.row(j.seq, worker))
+	}
+
+	for i, s := range sc.steps {
+		req, err := s.target.request(ctx, tm, j.seq, vars)
+		rec := newRecord(j, i, worker, intended)
+		intended = time.Time{} // only the first step is scheduled
+		if err != nil {
+			rec.fail(err)
+			out.add(rec)
+			return
+		}
+
+		var (
+			resp *http.Response
+			body []byte
+		)
+		rec, resp, body = send(ctx, client, req, rec, withTrace, s.needsBody)
+		rec.verify(s.target, resp, body)
+		for _, x := range s.extract {
+			if rec.failed {
+				break
+			}
+			v, err := x.extract(resp, body)
+			if err != nil {
+				rec.fail(err)
+				break
+			}
+			vars[x.name] = v
+		}
+		rec.partial = !rec.failed && i < len(sc.steps)-1
+		out.add(rec)
+		if rec.failed {
+			return
+		}
+	}
+}
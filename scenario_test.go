@@ -0,0 +1,126 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testScenario = `
+variables:
+  user: alice
+steps:
+  - name: login
+    method: POST
+    url: "{{.base}}/login"
+    headers:
+      Content-Type: application/json
+    body: '{"user": "{{.user}}"}'
+    extract:
+      token: {json: $.token}
+      session: {cookie: session}
+  - name: cart
+    url: "{{.base}}/cart?user={{.user}}"
+    headers:
+      Authorization: "Bearer {{.token}}"
+      Cookie: "session={{.session}}"
+    check: [status:200, contains:ok]
+`
+
+func TestScenario(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		seen []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen = append(seen, r.Method+" "+r.URL.RequestURI()+" "+r.Header.Get("Authorization")+" "+r.Header.Get("Cookie"))
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/login":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "s1"})
+			_, _ = w.Write([]byte(`{"token": "t1"}`))
+		case "/fail":
+			_, _ = w.Write([]byte(`{}`))
+		default:
+			_, _ = w.Write([]byte("ok"))
+		}
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "journey.yaml")
+	if err := os.WriteFile(path, []byte(testScenario), 0644); err != nil {
+		t.Fatalf("failed to create scenario file: %v", err)
+	}
+	defaults := targetDefaults{method: "GET", header: http.Header{}, vars: map[string]string{"base": srv.URL}}
+	sc, err := loadScenario(path, defaults)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(sc.steps) != 2 || !sc.steps[0].needsBody || !sc.steps[1].needsBody {
+		t.Fatalf("expected 2 steps reading the body, got %+v", sc.steps)
+	}
+	if n := len(sc.steps[1].target.checks); n != 2 {
+		t.Errorf("expected the second step to have 2 checks, got %d", n)
+	}
+	if names := newTargetSet(sc.targets()).names(); names[0] != "login" || names[1] != "cart" {
+		t.Errorf("expected steps login and cart, got %v", names)
+	}
+
+	sc.feed = &feed{rows: []map[string]string{{"base": srv.URL}}}
+	out := &recordLog{}
+	for i := range 2 {
+		sc.run(t.Context(), srv.Client(), newTemplater(0), job{seq: i}, time.Time{}, 0, out, false)
+	}
+	if len(out.records) != 4 {
+		t.Fatalf("expected 4 records, got %d", len(out.records))
+	}
+	for i, rec := range out.records {
+		if rec.failed || rec.target != i%2 {
+			t.Errorf("record %d: expected step %d to succeed, got %+v", i, i%2, rec)
+		}
+		if rec.partial != (i%2 == 0) {
+			t.Errorf("record %d: expected only the last step to complete the job, got partial %v", i, rec.partial)
+		}
+	}
+	if want := "GET /cart?user=alice Bearer t1 session=s1"; seen[1] != want {
+		t.Errorf("expected %q, got %q", want, seen[1])
+	}
+
+	// a failed extraction ends the run
+	sc.feed = nil
+	sc.vars = map[string]string{"base": srv.URL}
+	sc.steps[0].target, _ = newTarget(targetSpec{URL: srv.URL + "/fail"}, targetDefaults{method: "GET"})
+	out = &recordLog{}
+	sc.run(t.Context(), srv.Client(), newTemplater(0), job{}, time.Time{}, 0, out, false)
+	if len(out.records) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(out.records))
+	}
+	if rec := out.records[0]; !rec.failed || rec.errCategory != "extraction error" || rec.status != 200 || rec.partial {
+		t.Errorf("expected an extraction error of a 200 response to complete the job, got %+v", rec)
+	}
+}
+
+func TestLoadScenarioErrors(t *testing.T) {
+	for name, text := range map[string]string{
+		"no steps":      "variables: {a: b}\n",
+		"unknown field": "steps:\n  - url: http://example.com\n    extrct: {}\n",
+		"bad check":     "steps:\n  - url: http://example.com\n    check: [body:x]\n",
+		"bad extractor": "steps:\n  - url: http://example.com\n    extract: {a: {json: $.a, header: X-A}}\n",
+		"undefined var": "steps:\n  - url: \"http://example.com/{{.missing}}\"\n",
+		"not yaml":      "steps: [",
+		"relative url":  "steps:\n  - url: /login\n",
+	} {
+		path := filepath.Join(t.TempDir(), "scenario.yaml")
+		if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+			t.Fatalf("failed to create scenario file: %v", err)
+		}
+		if _, err := loadScenario(path, targetDefaults{method: "GET"}); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
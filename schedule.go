@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+// job is a single request to be made by a worker.
+type job struct {
+	seq      int
+	intended time.Time // when the schedule wanted the request sent; zero if unpaced
+	stage    int       // index of the load stage the job belongs to
+}
+
+// arrivals returns the intended send time of the i-th job, as an offset from
+// the start of the schedule, and the stage it belongs to. ok is false once
+// the schedule has no more jobs. Jobs are requested in order.
+type arrivals func(i int) (offset time.Duration, stage int, ok bool)
+
+// constantRate is a schedule of evenly spaced arrivals, at rate per second.
+func constantRate(rate float64) arrivals {
+	interval := time.Duration(float64(time.Second) / rate)
+	return func(i int) (time.Duration, int, bool) {
+		return time.Duration(i) * interval, 0, true
+	}
+}
+
+// timeline is a schedule that repeats a recorded sequence of arrivals: the
+// i-th job is sent offsets[i%n] into the (i/n)-th repetition, and each
+// repetition lasts cycle. Times are divided by speed, so that 2 replays twice
+// as fast. offsets must be in order, and no later than cycle.
+func timeline(offsets []time.Duration, cycle time.Duration, speed float64) arrivals {
+	return func(i int) (time.Duration, int, bool) {
+		n := len(offsets)
+		at := time.Duration(i/n)*cycle + offsets[i%n]
+		return time.Duration(float64(at) / speed), 0, true
+	}
+}
+
+// runWorkers starts n workers with start, staggering their starts with jitter
+// over about a second to avoid synchronized bursts, unless stagger is false.
+// It then feeds them jobs with feed, bounded by wall-clock time if duration is
+// positive. The duration starts once every worker has started, so that the run
+// gets all of it; in-flight requests still use ctx, so they are allowed to
+// finish.
+func runWorkers(ctx context.Context, n int, stagger bool, duration time.Duration, start func(id int), feed func(ctx context.Context)) {
+	for i := range n {
+		if stagger {
+			base := time.Second / time.Duration(n)
+			jitter := time.Duration(rand.Int64N(int64(base/2 + 1))) //nolint:gosec // jitter doesn't need cryptographic randomness
+			time.Sleep(base + jitter)
+		}
+		start(i)
+	}
+
+	if duration > 0 {
+		var stop context.CancelFunc
+		ctx, stop = context.WithTimeout(ctx, duration)
+		defer stop()
+	}
+	feed(ctx)
+}
+
+// feedJobs sends up to total jobs on jobCh, stopping early when ctx is done.
+// It returns the number of jobs sent.
+func feedJobs(ctx context.Context, jobCh chan<- job, total int) int {
+	for i := range total {
+		select {
+		case jobCh <- job{seq: i}:
+			// Job sent successfully
+		case <-ctx.Done():
+			// Context was canceled or the run duration elapsed, stop sending jobs
+			return i
+		}
+	}
+	return total
+}
+
+// growGrace is how long a due job may wait for a worker before the pool
+// grows. Workers are briefly busy between requests, and
+// growing for every such moment would inflate the pool, and the connections
+// it opens, well beyond the concurrency the rate needs.
+const growGrace = time.Millisecond
+
+// feedSchedule sends up to total jobs on jobCh at the times given by next,
+// regardless of how many responses are outstanding (open-loop). When no
+// worker takes a due job within growGrace, grow is called to add one to the
+// pool and the job goes to the next free worker. If the pool is already at its
+// maximum, the job waits and later jobs are sent back-to-back until the
+// schedule catches up. It returns the number of jobs sent.
+func feedSchedule(ctx context.Context, jobCh chan<- job, total int, next arrivals, grow func()) int {
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	defer timer.Stop()
+
+	start := time.Now()
+	for i := range total {
+		// due times are computed from the start so that delays don't accumulate
+		offset, stage, ok := next(i)
+		if !ok {
+			return i
+		}
+		j := job{seq: i, intended: start.Add(offset), stage: stage}
+		if wait := time.Until(j.intended); wait > 0 {
+			timer.Reset(wait)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				return i
+			}
+		}
+
+		timer.Reset(growGrace)
+		select {
+		case jobCh <- j:
+			timer.Stop()
+			continue // a worker took the job in time
+		case <-timer.C:
+			// all workers are busy, and the job is late
+		case <-ctx.Done():
+			return i
+		}
+		grow()
+		select {
+		case jobCh <- j:
+		case <-ctx.Done():
+			return i
+		}
+	}
+	return total
+}
+
+// pace returns a channel that delivers a fixed schedule of send times, one
+// every interval, until ctx is done. Unlike a time.Ticker, no times are dropped
+// when the receiver falls behind: overdue times are delivered back-to-back, so
+// the receiver can measure how late each request was against the schedule.
+//
+// The schedule starts when the first value is received, which is the zero
+// time, meaning "now".
+func pace(ctx context.Context, interval time.Duration) <-chan time.Time {
+	ch := make(chan time.Time)
+	go func() {
+		select {
+		case ch <- time.Time{}:
+		case <-ctx.Done():
+			return
+		}
+		next := time.Now().Add(interval)
+		timer := time.NewTimer(interval)
+		defer timer.Stop()
+		for {
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case ch <- next:
+			case <-ctx.Done():
+				return
+			}
+			next = next.Add(interval)
+			timer.Reset(max(time.Until(next), 0))
+		}
+	}()
+	return ch
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFeedJobs(t *testing.T) {
+	// Test sending all jobs
+	jobCh := make(chan job, 5)
+	sent := feedJobs(t.Context(), jobCh, 5)
+	close(jobCh)
+	if sent != 5 {
+		t.Errorf("expected 5 jobs sent, got %d", sent)
+	}
+	i := 0
+	for j := range jobCh {
+		if j.seq != i {
+			t.Errorf("expected job %d, got %d", i, j.seq)
+		}
+		i++
+	}
+
+	// Test stopping when the duration elapses with no receiver
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	sent = feedJobs(ctx, make(chan job), math.MaxInt-1)
+	if sent != 0 {
+		t.Errorf("expected 0 jobs sent, got %d", sent)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected feedJobs to stop after timeout, took %s", elapsed)
+	}
+}
+
+// TestFeedSchedulePacing tests that jobs are spread out at the requested rate
+func TestFeedSchedulePacing(t *testing.T) {
+	jobCh := make(chan job)
+	var received []time.Time
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for range jobCh {
+			received = append(received, time.Now())
+		}
+	}()
+
+	start := time.Now()
+	sent := feedSchedule(t.Context(), jobCh, 5, constantRate(100), func() {})
+	close(jobCh)
+	wg.Wait()
+
+	if sent != 5 {
+		t.Errorf("expected 5 jobs sent, got %d", sent)
+	}
+	if len(received) != 5 {
+		t.Fatalf("expected 5 jobs received, got %d", len(received))
+	}
+	// 5 jobs at 100/s are due at 0, 10, 20, 30 and 40ms
+	if elapsed := received[4].Sub(start); elapsed < 40*time.Millisecond {
+		t.Errorf("expected last job after 40ms, got %s", elapsed)
+	}
+}
+
+// TestFeedScheduleGrowsPool tests that grow is called when no worker is idle
+func TestFeedScheduleGrowsPool(t *testing.T) {
+	jobCh := make(chan job)
+	var wg sync.WaitGroup
+	grown := 0
+	grow := func() {
+		grown++
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-jobCh // take a single job, then stay "busy"
+		}()
+	}
+
+	sent := feedSchedule(t.Context(), jobCh, 3, constantRate(1000), grow)
+	wg.Wait()
+
+	if sent != 3 {
+		t.Errorf("expected 3 jobs sent, got %d", sent)
+	}
+	if grown != 3 {
+		t.Errorf("expected pool to grow 3 times, got %d", grown)
+	}
+}
+
+// TestFeedScheduleKeepsPool tests that the pool does not grow for a worker
+// that is briefly busy between requests, when it keeps up with the rate
+func TestFeedScheduleKeepsPool(t *testing.T) {
+	jobCh := make(chan job)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range jobCh {
+			// a fast request, spun rather than slept to be shorter than
+			// the timer resolution
+			for start := time.Now(); time.Since(start) < 200*time.Microsecond; {
+			}
+		}
+	}()
+
+	grown := 0
+	sent := feedSchedule(t.Context(), jobCh, 200, constantRate(2000), func() { grown++ })
+	close(jobCh)
+	<-done
+
+	if sent != 200 {
+		t.Errorf("expected 200 jobs sent, got %d", sent)
+	}
+	// a stall of the test process may still make a job late
+	if grown > 2 {
+		t.Errorf("expected the pool not to grow, grew %d times", grown)
+	}
+}
+
+// TestFeedScheduleCancellation tests that the scheduler stops when ctx is done
+func TestFeedScheduleCancellation(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+
+	// no workers and a pool that cannot grow
+	sent := feedSchedule(ctx, make(chan job), 10, constantRate(1000), func() {})
+	if sent != 0 {
+		t.Errorf("expected 0 jobs sent, got %d", sent)
+	}
+}
+
+// TestPace tests that overdue send times are delivered rather than dropped
+func TestPace(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	interval := 10 * time.Millisecond
+	ch := pace(ctx, interval)
+
+	// The first value starts the schedule
+	if first := <-ch; !first.IsZero() {
+		t.Errorf("expected zero time for first value, got %s", first)
+	}
+	start := time.Now()
+
+	// Fall behind by several intervals
+	time.Sleep(5 * interval)
+
+	prev := <-ch
+	for range 3 {
+		next := <-ch
+		if got := next.Sub(prev); got != interval {
+			t.Errorf("expected consecutive send times %s apart, got %s", interval, got)
+		}
+		prev = next
+	}
+	// the overdue times were delivered without waiting for the clock
+	if elapsed := time.Since(start); elapsed > 8*interval {
+		t.Errorf("expected overdue times to be delivered immediately, took %s", elapsed)
+	}
+}
+
+func TestTimeline(t *testing.T) {
+	offsets := []time.Duration{0, 100 * time.Millisecond, 250 * time.Millisecond}
+	next := timeline(offsets, time.Second, 1)
+	for i, want := range []time.Duration{0, 100 * time.Millisecond, 250 * time.Millisecond, time.Second, 1100 * time.Millisecond} {
+		got, stage, ok := next(i)
+		if got != want || stage != 0 || !ok {
+			t.Errorf("job %d: expected %s in stage 0, got %s in stage %d (ok %v)", i, want, got, stage, ok)
+		}
+	}
+
+	// at twice the speed everything happens in half the time
+	fast := timeline(offsets, time.Second, 2)
+	if got, _, _ := fast(4); got != 550*time.Millisecond {
+		t.Errorf("expected 550ms, got %s", got)
+	}
+}
+
+// TestRunWorkersDuration tests that the duration of a run starts once the
+// workers have started, so that a duration shorter than their stagger still
+// sends requests.
+func TestRunWorkersDuration(t *testing.T) {
+	jobCh := make(chan job)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	received := 0
+	start := time.Now()
+	var fed time.Duration
+	runWorkers(t.Context(), 4, true, 100*time.Millisecond, func(int) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range jobCh {
+				mu.Lock()
+				received++
+				mu.Unlock()
+				time.Sleep(time.Millisecond)
+			}
+		}()
+	}, func(ctx context.Context) {
+		if time.Since(start) < time.Second {
+			t.Errorf("expected feeding to start after the stagger, started after %s", time.Since(start))
+		}
+		feedStart := time.Now()
+		feedJobs(ctx, jobCh, math.MaxInt-1)
+		fed = time.Since(feedStart)
+	})
+	close(jobCh)
+	wg.Wait()
+
+	if received == 0 {
+		t.Error("expected requests to be sent within the duration")
+	}
+	if fed < 90*time.Millisecond || fed > time.Second {
+		t.Errorf("expected jobs to be fed for the duration, fed for %s", fed)
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// slo is the service level a capacity search must sustain.
+type slo struct {
+	p99       time.Duration // of response time, from the intended send time
+	errorRate float64       // fraction of failed requests
+}
+
+func (s slo) String() string {
+	return fmt.Sprintf("p99 < %s, errors < %.2f%%", s.p99, s.errorRate*100)
+}
+
+// evaluate reports the p99 response time and error rate of a window of
+// results, and whether they meet the SLO. An empty window never meets it.
+func (s slo) evaluate(window *stats) (p99 time.Duration, errRate float64, ok bool) {
+	if window == nil || window.count == 0 {
+		return 0, 0, false
+	}
+	p99 = window.response.percentile(0.99)
+	errRate = float64(window.failed) / float64(window.count)
+	return p99, errRate, window.response.count() > 0 && p99 < s.p99 && errRate < s.errorRate
+}
+
+// searchStep is the outcome of one step of a capacity search.
+type searchStep struct {
+	Rate      float64 `json:"rate"`
+	P99       float64 `json:"p99"` // seconds
+	ErrorRate float64 `json:"error_rate"`
+	OK        bool    `json:"ok"`
+	Complete  bool    `json:"complete"` // false if the run ended before the step did
+}
+
+// searchReport is the outcome of a capacity search.
+type searchReport struct {
+	SLOP99       float64      `json:"slo_p99"` // seconds
+	SLOErrorRate float64      `json:"slo_error_rate"`
+	Steps        []searchStep `json:"steps"`
+	HighestRate  float64      `json:"highest_sustainable_rate"` // 0 if no step met the SLO
+}
+
+// holdAt is a schedule of evenly spaced arrivals that all belong to stage.
+func holdAt(rate float64, stage int) arrivals {
+	next := constantRate(rate)
+	return func(i int) (time.Duration, int, bool) {
+		offset, _, ok := next(i)
+		return offset, stage, ok
+	}
+}
+
+// searchCapacity offers increasing load, step requests/sec more every
+// interval, until the results of a step violate target. Each step is recorded
+// as a stage of results, so the worker pool and result set are shared across
+// steps while every step is judged on its own window of stats. It stops
+// early when ctx is done or total jobs have been sent.
+func searchCapacity(
+	ctx context.Context,
+	jobCh chan<- job,
+	total int,
+	step float64,
+	interval time.Duration,
+	target slo,
+	results *resultSet,
+	grow func(),
+) []searchStep {
+	var steps []searchStep
+	for k := 1; total > 0; k++ {
+		rate := step * float64(k)
+
+		// an instantaneous stage jumps to the step's rate, which is then held
+		results.mu.Lock()
+		results.stages = append(results.stages, stage{target: rate}, stage{duration: interval, target: rate})
+		idx := len(results.stages) - 1
+		results.mu.Unlock()
+
+		stepCtx, stop := context.WithTimeout(ctx, interval)
+		sent := feedSchedule(stepCtx, jobCh, total, holdAt(rate, idx), grow)
+		stop()
+		total -= sent
+
+		// let the step's in-flight requests finish before judging it
+		results.waitStage(ctx, idx, sent)
+		p99, errRate, ok := target.evaluate(results.stage(idx))
+		steps = append(steps, searchStep{Rate: rate, P99: p99.Seconds(), ErrorRate: errRate, OK: ok, Complete: ctx.Err() == nil})
+		if !ok || ctx.Err() != nil {
+			break
+		}
+	}
+	return steps
+}
+
+func newSearchReport(steps []searchStep, target slo) *searchReport {
+	rep := &searchReport{SLOP99: target.p99.Seconds(), SLOErrorRate: target.errorRate, Steps: steps}
+	for _, s := range steps {
+		if s.OK && s.Complete {
+			rep.HighestRate = s.Rate
+		}
+	}
+	return rep
+}
+
+// searchSummary formats the outcome of each step and the highest rate that
+// met the SLO.
+func searchSummary(rep *searchReport) string {
+	var sb strings.Builder
+	target := slo{p99: time.Duration(rep.SLOP99 * float64(time.Second)), errorRate: rep.SLOErrorRate}
+	fmt.Fprintf(&sb, "\nCapacity search (%s):\n", target)
+	for _, s := range rep.Steps {
+		verdict := "ok"
+		switch {
+		case !s.Complete:
+			verdict = "incomplete"
+		case !s.OK:
+			verdict = "SLO violated"
+		}
+		fmt.Fprintf(&sb, "  %10g rps  p99 %.4f secs  %6.2f%% errors  %s\n", s.Rate, s.P99, s.ErrorRate*100, verdict)
+	}
+	if rep.HighestRate == 0 {
+		sb.WriteString("  No step met the SLO\n")
+	} else {
+		fmt.Fprintf(&sb, "  Highest sustainable rate: %g rps\n", rep.HighestRate)
+	}
+	return sb.String()
+}
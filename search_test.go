@@ -0,0 +1,111 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSLOEvaluate(t *testing.T) {
+	target := slo{p99: 250 * time.Millisecond, errorRate: 0.01}
+
+	window := func(records ...record) *stats {
+		s := newStats(3)
+		for _, rec := range records {
+			s.add(rec)
+		}
+		return s
+	}
+
+	// Test passing window
+	p99, errRate, ok := target.evaluate(window(
+		record{latency: 100 * time.Millisecond, status: 200},
+		record{latency: 200 * time.Millisecond, status: 200},
+	))
+	if !ok {
+		t.Errorf("expected SLO to be met, got p99 %s, error rate %v", p99, errRate)
+	}
+
+	// Test that time behind schedule counts against the SLO
+	p99, _, ok = target.evaluate(window(
+		record{latency: 100 * time.Millisecond, status: 200},
+		record{latency: 200 * time.Millisecond, wait: 100 * time.Millisecond, status: 200},
+	))
+	if ok || !within(p99, 300*time.Millisecond, 0.001) {
+		t.Errorf("expected SLO to be violated by p99 of 300ms, got %s", p99)
+	}
+
+	// Test error rate
+	_, errRate, ok = target.evaluate(window(record{latency: time.Millisecond, status: 200}, record{failed: true}))
+	if ok || errRate != 0.5 {
+		t.Errorf("expected SLO to be violated by error rate 0.5, got %v", errRate)
+	}
+
+	// Test empty window
+	if _, _, ok = target.evaluate(nil); ok {
+		t.Error("expected empty window to violate the SLO")
+	}
+}
+
+func TestSearchCapacity(t *testing.T) {
+	results := &resultSet{}
+	jobCh := make(chan job)
+
+	// A fake worker pool whose latency degrades as the rate goes up
+	var wg sync.WaitGroup
+	grow := func() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobCh {
+				latency := 10 * time.Millisecond
+				if j.stage > 3 {
+					latency = time.Second
+				}
+				results.add(record{latency: latency, status: 200, stage: j.stage})
+			}
+		}()
+	}
+
+	target := slo{p99: 250 * time.Millisecond, errorRate: 0.01}
+	steps := searchCapacity(t.Context(), jobCh, 1000, 100, 50*time.Millisecond, target, results, grow)
+	close(jobCh)
+	wg.Wait()
+
+	// steps are stages 1, 3 and 5, the last of which is too slow
+	if len(steps) != 3 {
+		t.Fatalf("expected 3 steps, got %d: %+v", len(steps), steps)
+	}
+	for i, want := range []bool{true, true, false} {
+		if steps[i].OK != want || !steps[i].Complete {
+			t.Errorf("step %d: expected ok=%v, got %+v", i, want, steps[i])
+		}
+		if steps[i].Rate != float64(100*(i+1)) {
+			t.Errorf("step %d: expected rate %d, got %v", i, 100*(i+1), steps[i].Rate)
+		}
+	}
+	if len(results.stages) != 6 {
+		t.Errorf("expected 6 stages recorded, got %d", len(results.stages))
+	}
+
+	rep := newSearchReport(steps, target)
+	if rep.HighestRate != 200 {
+		t.Errorf("expected highest rate 200, got %v", rep.HighestRate)
+	}
+	report := searchSummary(rep)
+	if !strings.Contains(report, "Highest sustainable rate: 200 rps") {
+		t.Errorf("missing highest rate in report:\n%s", report)
+	}
+	if !strings.Contains(report, "SLO violated") {
+		t.Errorf("missing violation in report:\n%s", report)
+	}
+}
+
+func TestSearchReportNoneMet(t *testing.T) {
+	target := slo{p99: time.Millisecond, errorRate: 0.01}
+	report := searchSummary(newSearchReport([]searchStep{{Rate: 10, P99: 1, Complete: true}}, target))
+	if !strings.Contains(report, "No step met the SLO") {
+		t.Errorf("expected no step to meet the SLO:\n%s", report)
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/cookiejar"
+)
+
+// workerClients returns the HTTP client of each worker, by id. Workers share
+// client, unless jar is set, which gives each worker its own cookie jar so
+// that it keeps the session of a distinct user, or isolate is set, which
+// gives each worker its own cookie jar and transport, so that it also keeps
+// its own connections.
+func workerClients(client *http.Client, jar, isolate bool) func(id int) *http.Client {
+	if !jar && !isolate {
+		return func(int) *http.Client { return client }
+	}
+	return func(int) *http.Client {
+		c := *client
+		c.Jar, _ = cookiejar.New(nil) // never fails without options
+		if tr, ok := c.Transport.(*http.Transport); ok && isolate {
+			tr = tr.Clone()
+			if tr.TLSClientConfig != nil && tr.TLSClientConfig.ClientSessionCache != nil {
+				// an isolated worker keeps its own session cache, so that it only resumes its own TLS sessions
+				tr.TLSClientConfig.ClientSessionCache = tls.NewLRUClientSessionCache(0)
+			}
+			c.Transport = tr
+		}
+		return &c
+	}
+}
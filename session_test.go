@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWorkerClients(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := r.Cookie("session"); err != nil {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: r.URL.Query().Get("id")})
+		}
+		c, _ := r.Cookie("session")
+		if c != nil {
+			_, _ = w.Write([]byte(c.Value))
+		}
+	}))
+	defer srv.Close()
+
+	get := func(c *http.Client, id string) string {
+		resp, err := c.Get(srv.URL + "?id=" + id)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer resp.Body.Close()
+		return resp.Header.Get("Set-Cookie")
+	}
+
+	shared := &http.Client{Transport: &http.Transport{}}
+	clients := workerClients(shared, false, false)
+	if clients(0) != shared || clients(1) != shared {
+		t.Error("expected workers to share the client")
+	}
+
+	clients = workerClients(shared, true, false)
+	a, b := clients(0), clients(1)
+	if a.Jar == nil || a.Jar == b.Jar {
+		t.Fatal("expected each worker to have its own cookie jar")
+	}
+	if a.Transport != shared.Transport {
+		t.Error("expected workers to share the transport")
+	}
+	if got := get(a, "a"); got == "" {
+		t.Error("expected the first request to set a cookie")
+	}
+	if got := get(a, "a"); got != "" {
+		t.Errorf("expected the worker to send its cookie back, got Set-Cookie %q", got)
+	}
+	if got := get(b, "b"); got == "" {
+		t.Error("expected another worker to have no cookie")
+	}
+	if shared.Jar != nil {
+		t.Error("expected the shared client to be left without a jar")
+	}
+
+	clients = workerClients(shared, false, true)
+	a, b = clients(0), clients(1)
+	if a.Jar == nil || a.Jar == b.Jar {
+		t.Error("expected isolated workers to have their own cookie jars")
+	}
+	if a.Transport == shared.Transport || a.Transport == b.Transport {
+		t.Error("expected isolated workers to have their own transports")
+	}
+}
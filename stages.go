@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// stage is one part of a load profile: the arrival rate ramps linearly from
+// the previous stage's target (or 0 for the first stage) to target over
+// duration. A stage with the same target as its predecessor holds the rate.
+type stage struct {
+	duration time.Duration
+	target   float64 // requests/sec at the end of the stage
+}
+
+// parseStages parses a comma separated list of duration:rps stages, e.g.
+// "1m:200,5m:200,30s:0" to ramp up to 200 rps over a minute, hold for five
+// minutes, then ramp down over 30 seconds.
+func parseStages(s string) ([]stage, error) {
+	var stages []stage
+	for part := range strings.SplitSeq(s, ",") {
+		d, r, ok := strings.Cut(strings.TrimSpace(part), ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid stage %q, must be duration:rps", part)
+		}
+		dur, err := time.ParseDuration(d)
+		if err != nil || dur < 0 {
+			return nil, fmt.Errorf("invalid stage duration %q", d)
+		}
+		target, err := strconv.ParseFloat(r, 64)
+		if err != nil || target < 0 || math.IsInf(target, 0) {
+			return nil, fmt.Errorf("invalid stage rate %q", r)
+		}
+		stages = append(stages, stage{duration: dur, target: target})
+	}
+	return stages, nil
+}
+
+// stageFrom returns the rate stage i starts at.
+func stageFrom(stages []stage, i int) float64 {
+	if i == 0 {
+		return 0
+	}
+	return stages[i-1].target
+}
+
+// stageLabel describes stage i, e.g. "0→200 rps over 1m0s".
+func stageLabel(stages []stage, i int) string {
+	from, to := stageFrom(stages, i), stages[i].target
+	if from == to {
+		return fmt.Sprintf("%g rps for %s", to, stages[i].duration)
+	}
+	return fmt.Sprintf("%g→%g rps over %s", from, to, stages[i].duration)
+}
+
+// stagedRate is a schedule of arrivals that follows the rate given by stages.
+func stagedRate(stages []stage) arrivals {
+	var (
+		idx   int           // current stage
+		start time.Duration // offset of the current stage
+		base  float64       // arrivals before the current stage
+	)
+	return func(i int) (time.Duration, int, bool) {
+		for ; idx < len(stages); idx++ {
+			r0, r1 := stageFrom(stages, idx), stages[idx].target
+			d := stages[idx].duration.Seconds()
+
+			// the number of arrivals in the stage is the area under the ramp
+			x := float64(i) - base
+			if count := (r0 + r1) / 2 * d; x >= count {
+				base += count
+				start += stages[idx].duration
+				continue
+			}
+
+			// Solve r0·τ + a·τ²/2 = x for the offset τ into the stage, where a
+			// is the ramp's slope. This form is stable for a = 0 and a < 0.
+			a := (r1 - r0) / d
+			tau := 0.0
+			if x > 0 {
+				tau = 2 * x / (r0 + math.Sqrt(r0*r0+2*a*x))
+			}
+			return start + time.Duration(tau*float64(time.Second)), idx, true
+		}
+		return 0, 0, false
+	}
+}
+
+// stageReport is the statistics of one stage of a load profile.
+type stageReport struct {
+	Stage          int            `json:"stage"` // position in the profile, from 1
+	Label          string         `json:"label"`
+	Duration       float64        `json:"duration_secs"`
+	From           float64        `json:"from_rps"`
+	Target         float64        `json:"target_rps"`
+	Requests       int            `json:"requests"`
+	RequestsPerSec float64        `json:"requests_per_sec"`
+	Failed         int            `json:"failed"`
+	Latency        *latencyReport `json:"latency"`
+}
+
+// stageReports computes request counts, rates and latencies per stage.
+// Instantaneous stages are left out, since nothing is sent during them.
+func stageReports(stages []stage, byStage []*stats) []stageReport {
+	var reports []stageReport
+	for i, st := range stages {
+		if st.duration == 0 {
+			continue
+		}
+		s := &stats{latency: &histogram{}}
+		if i < len(byStage) && byStage[i] != nil {
+			s = byStage[i]
+		}
+		reports = append(reports, stageReport{
+			Stage:          i + 1,
+			Label:          stageLabel(stages, i),
+			Duration:       st.duration.Seconds(),
+			From:           stageFrom(stages, i),
+			Target:         st.target,
+			Requests:       s.count,
+			RequestsPerSec: float64(s.count) / st.duration.Seconds(),
+			Failed:         s.failed,
+			Latency:        newLatencyReport(s.latency),
+		})
+	}
+	return reports
+}
+
+// stageDistribution formats the per-stage reports.
+func stageDistribution(reports []stageReport) string {
+	var sb strings.Builder
+	sb.WriteString("\nStage distribution:\n")
+	for _, s := range reports {
+		fmt.Fprintf(&sb, "  [%d] %s\n", s.Stage, s.Label)
+		fmt.Fprintf(&sb, "      %d requests, %.4f req/sec, %d failed, average %.4f secs\n",
+			s.Requests, s.RequestsPerSec, s.Failed, s.Latency.Mean)
+		fmt.Fprintf(&sb, "      50%% in %.4f secs, 90%% in %.4f secs, 99%% in %.4f secs\n",
+			s.Latency.P50, s.Latency.P90, s.Latency.P99)
+	}
+	return sb.String()
+}
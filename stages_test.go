@@ -0,0 +1,111 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseStages(t *testing.T) {
+	stages, err := parseStages("1m:200, 5m:200,30s:0")
+	if err != nil {
+		t.Fatalf("parseStages failed: %v", err)
+	}
+	expected := []stage{
+		{duration: time.Minute, target: 200},
+		{duration: 5 * time.Minute, target: 200},
+		{duration: 30 * time.Second, target: 0},
+	}
+	if len(stages) != len(expected) {
+		t.Fatalf("expected %d stages, got %d", len(expected), len(stages))
+	}
+	for i := range expected {
+		if stages[i] != expected[i] {
+			t.Errorf("stage %d: expected %+v, got %+v", i, expected[i], stages[i])
+		}
+	}
+
+	// Test invalid stages
+	for _, s := range []string{"", "1m", "x:200", "1m:x", "1m:-5", "-1m:5"} {
+		if _, err := parseStages(s); err == nil {
+			t.Errorf("parseStages(%q) should return error", s)
+		}
+	}
+}
+
+func TestStagedRateHold(t *testing.T) {
+	// an instantaneous stage jumps straight to 100 rps, which is then held
+	next := stagedRate([]stage{{0, 100}, {time.Second, 100}})
+
+	for i := range 100 {
+		offset, st, ok := next(i)
+		if !ok {
+			t.Fatalf("expected arrival %d, schedule ended", i)
+		}
+		if st != 1 {
+			t.Errorf("arrival %d: expected stage 1, got %d", i, st)
+		}
+		if want := time.Duration(i) * 10 * time.Millisecond; offset < want-time.Microsecond || offset > want+time.Microsecond {
+			t.Errorf("arrival %d: expected offset %s, got %s", i, want, offset)
+		}
+	}
+	if _, _, ok := next(100); ok {
+		t.Error("expected schedule to end after 100 arrivals")
+	}
+}
+
+func TestStagedRateRamp(t *testing.T) {
+	// ramping 0→100 rps over 1s sends 50 requests, then 50 more ramping down
+	next := stagedRate([]stage{{time.Second, 100}, {time.Second, 0}})
+
+	var offsets []time.Duration
+	stageCounts := map[int]int{}
+	for i := 0; ; i++ {
+		offset, st, ok := next(i)
+		if !ok {
+			break
+		}
+		offsets = append(offsets, offset)
+		stageCounts[st]++
+	}
+
+	if stageCounts[0] != 50 || stageCounts[1] != 50 {
+		t.Errorf("expected 50 arrivals per stage, got %v", stageCounts)
+	}
+	for i := 1; i < len(offsets); i++ {
+		if offsets[i] < offsets[i-1] {
+			t.Fatalf("offsets must not decrease: %s then %s", offsets[i-1], offsets[i])
+		}
+	}
+	// arrivals are sparse at the start of the ramp, dense at its peak
+	first := offsets[1] - offsets[0]
+	peak := offsets[50] - offsets[49]
+	if first <= peak {
+		t.Errorf("expected gaps to shrink as the rate ramps up, got %s then %s", first, peak)
+	}
+	if last := offsets[len(offsets)-1]; last > 2*time.Second {
+		t.Errorf("expected all arrivals within 2s, last at %s", last)
+	}
+}
+
+func TestStageDistribution(t *testing.T) {
+	rs := &resultSet{stages: []stage{{time.Second, 10}, {time.Second, 10}, {time.Second, 0}}}
+	rs.add(record{latency: 100 * time.Millisecond, status: 200, stage: 0})
+	rs.add(record{latency: 200 * time.Millisecond, status: 200, stage: 1})
+	rs.add(record{failed: true, stage: 1})
+
+	out := stageDistribution(stageReports(rs.stages, rs.byStage))
+	if !strings.Contains(out, "[1] 0→10 rps over 1s") {
+		t.Errorf("missing ramp stage label in output:\n%s", out)
+	}
+	if !strings.Contains(out, "[2] 10 rps for 1s") {
+		t.Errorf("missing hold stage label in output:\n%s", out)
+	}
+	if !strings.Contains(out, "2 requests, 2.0000 req/sec, 1 failed, average 0.2000 secs") {
+		t.Errorf("missing stage metrics in output:\n%s", out)
+	}
+	// stages without records are still listed
+	if !strings.Contains(out, "[3] 10→0 rps over 1s\n      0 requests") {
+		t.Errorf("missing empty stage in output:\n%s", out)
+	}
+}
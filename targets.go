@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+)
+
+// target is one of the requests a run sends.
+type target struct {
+	name    string           // for reports, "METHOD URL" unless named
+	req     *http.Request    // template, cloned for each request
+	tpl     *requestTemplate // parts rendered for each request, if any
+	weight  int
+	checks  []*check     // of each response
+	success statusPolicy // statuses of successful responses; nil for the default, see verify
+}
+
+// targetDefaults are the parts of a target given by flags.
+type targetDefaults struct {
+	method   string
+	header   http.Header
+	body     []byte
+	template bool              // render {{...}} templates, see requestTemplate
+	vars     map[string]string // a sample of template variables, to check templates with
+	checks   []*check
+	success  statusPolicy
+}
+
+// targetSpec is a target as given on a line of a targets file. Fields that
+// are left out default to the -m, -H and -d flags.
+type targetSpec struct {
+	Name    string            `json:"name"`
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers"` // added to the -H headers
+	Body    string            `json:"body"`    // @file reads a file, like -d
+	Weight  int               `json:"weight"`  // relative share of requests, default 1
+}
+
+// newTarget builds the request template of spec, on top of the defaults
+// given by flags.
+func newTarget(spec targetSpec, defaults targetDefaults) (*target, error) {
+	method, body := defaults.method, defaults.body
+	if spec.Method != "" {
+		method = spec.Method
+	}
+	if spec.Body != "" {
+		var err error
+		if body, err = loadBody(spec.Body); err != nil {
+			return nil, fmt.Errorf("failed to read body: %w", err)
+		}
+	}
+	if spec.Weight < 0 {
+		return nil, fmt.Errorf("weight must be ≥ 0, got %d", spec.Weight)
+	}
+
+	header := defaults.header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	for k, v := range spec.Headers {
+		header.Set(k, v)
+	}
+
+	var tpl *requestTemplate
+	rawURL := spec.URL
+	if defaults.template {
+		var err error
+		if tpl, err = parseRequestTemplate(spec.URL, header, body); err != nil {
+			return nil, err
+		}
+		if tpl != nil && tpl.url {
+			// check that the URL renders to a valid one
+			u, err := newTemplater(0).render(tpl, "url", defaults.vars)
+			if err != nil {
+				return nil, fmt.Errorf("invalid template: %w", err)
+			}
+			rawURL = string(u)
+		}
+	}
+
+	req, err := newRequestTemplate(method, rawURL, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = header
+
+	t := &target{name: spec.Name, req: req, tpl: tpl, weight: spec.Weight, checks: defaults.checks, success: defaults.success}
+	if t.name == "" {
+		t.name = req.Method + " " + spec.URL
+	}
+	if t.weight == 0 {
+		t.weight = 1
+	}
+	return t, nil
+}
+
+// request returns the next request to send to t: a clone of its template,
+// with any templated parts rendered by tm for the seq-th request of the run,
+// with vars as {{.name}}.
+func (t *target) request(ctx context.Context, tm *templater, seq int, vars map[string]string) (*http.Request, error) {
+	// Clone request (cheap shallow copy, new body)
+	req := t.req.Clone(ctx)
+	if t.tpl == nil {
+		if req.Body != nil {
+			_ = req.Body.Close() // close old (no‑op for NopCloser)
+			req.Body, _ = t.req.GetBody()
+		}
+		return req, nil
+	}
+
+	tm.seq = seq
+	if t.tpl.url {
+		raw, err := tm.render(t.tpl, "url", vars)
+		if err != nil {
+			return nil, err
+		}
+		u, err := url.Parse(string(raw))
+		if err != nil {
+			return nil, err
+		}
+		req.URL, req.Host = u, u.Host
+	}
+	for _, h := range t.tpl.headers {
+		v, err := tm.render(t.tpl, h.name, vars)
+		if err != nil {
+			return nil, err
+		}
+		req.Header[h.key][h.index] = string(v)
+	}
+	if t.tpl.body {
+		body, err := tm.render(t.tpl, "body", vars)
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.ContentLength = int64(len(body))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+	} else if req.Body != nil {
+		req.Body, _ = t.req.GetBody()
+	}
+	return req, nil
+}
+
+// newRequestTemplate builds a request to be cloned for each request sent.
+func newRequestTemplate(method, rawURL string, body []byte) (*http.Request, error) {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %w", err)
+	}
+	if parsedURL.Scheme == "" {
+		return nil, fmt.Errorf("URL must include scheme: %q", rawURL)
+	}
+	req, err := http.NewRequestWithContext(context.Background(), strings.ToUpper(method), parsedURL.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("request build: %w", err)
+	}
+	if len(body) > 0 {
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+	return req, nil
+}
+
+// loadTargets reads a targets file: one JSON targetSpec per line. Blank lines
+// and lines starting with # are skipped.
+func loadTargets(path string, defaults targetDefaults) ([]*target, error) {
+	f, err := os.Open(path) //nolint:gosec // User explicitly specified file path via -targets flag
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var targets []*target
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		var spec targetSpec
+		if err := json.Unmarshal([]byte(text), &spec); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		t, err := newTarget(spec, defaults)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		targets = append(targets, t)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("%s has no targets", path)
+	}
+	return targets, nil
+}
+
+// targetSet picks targets at random in proportion to their weights, or in a
+// fixed order. It is safe for concurrent use.
+type targetSet struct {
+	targets    []*target
+	cumulative []int     // running total of weights
+	order      []int     // if set, the seq-th request goes to order[seq mod len(order)]
+	feed       *feed     // variables of templates, if any
+	scenario   *scenario // if set, each job runs the scenario, whose steps are the targets
+}
+
+func newTargetSet(targets []*target) *targetSet {
+	ts := &targetSet{targets: targets}
+	total := 0
+	for _, t := range targets {
+		total += t.weight
+		ts.cumulative = append(ts.cumulative, total)
+	}
+	return ts
+}
+
+// pick returns the index of the target of the seq-th request of the run.
+func (ts *targetSet) pick(seq int) int {
+	if len(ts.targets) == 1 {
+		return 0
+	}
+	if len(ts.order) > 0 {
+		return ts.order[seq%len(ts.order)]
+	}
+	n := rand.IntN(ts.cumulative[len(ts.cumulative)-1]) //nolint:gosec // target selection doesn't need cryptographic randomness
+	i, _ := slices.BinarySearch(ts.cumulative, n+1)
+	return i
+}
+
+// request picks a target and returns its index and the request to send to
+// it, for the seq-th request of the run, rendered by tm.
+func (ts *targetSet) request(ctx context.Context, tm *templater, seq int) (int, *http.Request, error) {
+	idx := ts.pick(seq)
+	var vars map[string]string
+	if ts.feed != nil {
+		vars = ts.feed.row(seq, tm.worker)
+	}
+	req, err := ts.targets[idx].request(ctx, tm, seq, vars)
+	return idx, req, err
+}
+
+// names returns the names of the targets, in order.
+func (ts *targetSet) names() []string {
+	names := make([]string, len(ts.targets))
+	for i, t := range ts.targets {
+		names[i] = t.name
+	}
+	return names
+}
+
+// targetReport is the statistics of one target of a run.
+type targetReport struct {
+	Name           string         `json:"name"`
+	Requests       int            `json:"requests"`
+	RequestsPerSec float64        `json:"requests_per_sec"`
+	Failed         int            `json:"failed"`
+	Latency        *latencyReport `json:"latency"`
+	StatusCodes    map[int]int    `json:"status_codes"`
+}
+
+// targetReports computes request counts, rates and latencies per target.
+func targetReports(names []string, byTarget []*stats, duration float64) []targetReport {
+	reports := make([]targetReport, len(names))
+	for i, name := range names {
+		s := &stats{latency: &histogram{}, statusCount: map[int]int{}}
+		if i < len(byTarget) && byTarget[i] != nil {
+			s = byTarget[i]
+		}
+		reports[i] = targetReport{
+			Name:        name,
+			Requests:    s.count,
+			Failed:      s.failed,
+			Latency:     newLatencyReport(s.latency),
+			StatusCodes: maps.Clone(s.statusCount),
+		}
+		if duration > 0 {
+			reports[i].RequestsPerSec = float64(s.count) / duration
+		}
+	}
+	return reports
+}
+
+// targetDistribution formats the per-target reports.
+func targetDistribution(reports []targetReport) string {
+	var sb strings.Builder
+	sb.WriteString("\nTarget distribution:\n")
+	for i, t := range reports {
+		fmt.Fprintf(&sb, "  [%d] %s\n", i+1, t.Name)
+		fmt.Fprintf(&sb, "      %d requests, %.4f req/sec, %d failed, average %.4f secs\n",
+			t.Requests, t.RequestsPerSec, t.Failed, t.Latency.Mean)
+		fmt.Fprintf(&sb, "      50%% in %.4f secs, 90%% in %.4f secs, 99%% in %.4f secs\n",
+			t.Latency.P50, t.Latency.P90, t.Latency.P99)
+	}
+	return sb.String()
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewTarget(t *testing.T) {
+	header := http.Header{"Authorization": {"Bearer token"}}
+
+	// flags are the defaults
+	tgt, err := newTarget(targetSpec{URL: "http://example.com/a"}, targetDefaults{method: "post", header: header, body: []byte("flag body")})
+	if err != nil {
+		t.Fatalf("newTarget failed: %v", err)
+	}
+	if tgt.name != "POST http://example.com/a" || tgt.weight != 1 {
+		t.Errorf("expected default name and weight, got %q and %d", tgt.name, tgt.weight)
+	}
+	if tgt.req.Header.Get("Authorization") != "Bearer token" {
+		t.Errorf("expected -H headers, got %v", tgt.req.Header)
+	}
+	body, _ := tgt.req.GetBody()
+	if b, _ := io.ReadAll(body); string(b) != "flag body" {
+		t.Errorf("expected flag body, got %q", b)
+	}
+
+	// and a spec overrides them
+	tgt, err = newTarget(targetSpec{
+		Name:    "search",
+		Method:  "GET",
+		URL:     "http://example.com/search",
+		Headers: map[string]string{"Authorization": "Basic abc", "Accept": "text/html"},
+		Weight:  3,
+	}, targetDefaults{method: "POST", header: header})
+	if err != nil {
+		t.Fatalf("newTarget failed: %v", err)
+	}
+	if tgt.name != "search" || tgt.weight != 3 || tgt.req.Method != http.MethodGet {
+		t.Errorf("expected spec name, weight and method, got %q, %d and %s", tgt.name, tgt.weight, tgt.req.Method)
+	}
+	if tgt.req.Header.Get("Authorization") != "Basic abc" || tgt.req.Header.Get("Accept") != "text/html" {
+		t.Errorf("expected spec headers, got %v", tgt.req.Header)
+	}
+	if header.Get("Authorization") != "Bearer token" {
+		t.Error("expected the default headers to be left unchanged")
+	}
+
+	for _, spec := range []targetSpec{{URL: "example.com"}, {URL: "http://example.com", Weight: -1}, {URL: "http://example.com", Body: "@missing.file"}} {
+		if _, err := newTarget(spec, targetDefaults{method: "GET"}); err == nil {
+			t.Errorf("expected error for %+v", spec)
+		}
+	}
+}
+
+func TestLoadTargets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "targets.jsonl")
+	content := `# endpoints
+{"url": "http://example.com/home", "weight": 9}
+
+{"method": "POST", "url": "http://example.com/cart", "body": "{\"sku\": 1}"}
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to create targets file: %v", err)
+	}
+
+	targets, err := loadTargets(path, targetDefaults{method: "GET"})
+	if err != nil {
+		t.Fatalf("loadTargets failed: %v", err)
+	}
+	if len(targets) != 2 {
+		t.Fatalf("expected 2 targets, got %d", len(targets))
+	}
+	if targets[0].name != "GET http://example.com/home" || targets[0].weight != 9 {
+		t.Errorf("unexpected first target %q with weight %d", targets[0].name, targets[0].weight)
+	}
+	if targets[1].req.Method != http.MethodPost || targets[1].req.GetBody == nil {
+		t.Errorf("expected a POST with a body, got %s", targets[1].req.Method)
+	}
+
+	if err := os.WriteFile(path, []byte("{\"url\": \"http://example.com\"}\n{\"url\": \"nope\"}\n"), 0644); err != nil {
+		t.Fatalf("failed to create targets file: %v", err)
+	}
+	if _, err := loadTargets(path, targetDefaults{method: "GET"}); err == nil || !strings.Contains(err.Error(), ":2:") {
+		t.Errorf("expected error on line 2, got %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte("# nothing\n"), 0644); err != nil {
+		t.Fatalf("failed to create targets file: %v", err)
+	}
+	if _, err := loadTargets(path, targetDefaults{method: "GET"}); err == nil {
+		t.Error("expected error for a file without targets")
+	}
+}
+
+func TestTargetSetPick(t *testing.T) {
+	ts := newTargetSet([]*target{{name: "a", weight: 1}, {name: "b", weight: 0}, {name: "c", weight: 3}})
+	// a weight of 0 is only possible for hand-built targets, which are never picked
+	counts := make([]int, 3)
+	for range 40000 {
+		counts[ts.pick(0)]++
+	}
+	if counts[1] != 0 {
+		t.Errorf("expected a target of weight 0 never to be picked, got %d", counts[1])
+	}
+	if ratio := float64(counts[2]) / float64(counts[0]); ratio < 2.7 || ratio > 3.3 {
+		t.Errorf("expected picks in a 1:3 ratio, got %v", counts)
+	}
+
+	ts.order = []int{2, 0, 1, 1}
+	for seq, want := range []int{2, 0, 1, 1, 2} {
+		if got := ts.pick(seq); got != want {
+			t.Errorf("expected request %d to go to target %d in order, got %d", seq, want, got)
+		}
+	}
+
+	single := newTargetSet([]*target{{name: "only", weight: 1}})
+	if single.pick(5) != 0 {
+		t.Error("expected the only target to be picked")
+	}
+}
+
+func TestResultSetByTarget(t *testing.T) {
+	rs := &resultSet{start: time.Now().Add(-2 * time.Second), end: time.Now(), targets: []string{"a", "b"}}
+	rs.add(record{latency: time.Millisecond, status: 200, target: 0})
+	rs.add(record{latency: 3 * time.Millisecond, status: 200, target: 1})
+	rs.add(record{latency: time.Millisecond, status: 503, target: 1})
+
+	rep := rs.report()
+	if len(rep.Targets) != 2 {
+		t.Fatalf("expected 2 target reports, got %d", len(rep.Targets))
+	}
+	b := rep.Targets[1]
+	if b.Name != "b" || b.Requests != 2 || b.StatusCodes[503] != 1 {
+		t.Errorf("unexpected report for target b: %+v", b)
+	}
+	if b.RequestsPerSec < 0.9 || b.RequestsPerSec > 1.1 {
+		t.Errorf("expected about 1 req/sec, got %v", b.RequestsPerSec)
+	}
+
+	text := targetDistribution(rep.Targets)
+	if !strings.Contains(text, "Target distribution:") || !strings.Contains(text, "[2] b") {
+		t.Errorf("unexpected distribution:\n%s", text)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	mathrand "math/rand/v2"
+	"net/http"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// templateFuncs are the functions available to request templates. seq and
+// workerID are placeholders, bound to the request being rendered by each
+// worker's templater.
+var templateFuncs = template.FuncMap{
+	"uuid":     newUUID,
+	"randInt":  randInt,
+	"now":      func() string { return time.Now().Format(time.RFC3339Nano) },
+	"env":      os.Getenv,
+	"seq":      func() int { return 0 },
+	"workerID": func() int { return 0 },
+}
+
+// requestTemplate is the parts of a target that are rendered for each
+// request, as named templates of one set: "url", "body", and "header N" for
+// the N-th templated header value.
+type requestTemplate struct {
+	set     *template.Template
+	url     bool
+	body    bool
+	headers []templatedHeader
+}
+
+// templatedHeader is a header value that is rendered for each request.
+type templatedHeader struct {
+	key   string
+	index int // of the value, among the values of key
+	name  string
+}
+
+// hasTemplate reports whether s contains a template action.
+func hasTemplate(s string) bool { return strings.Contains(s, "{{") }
+
+// parseRequestTemplate parses the templates in the URL, header values and
+// body of a request. It returns nil if none of them contain a template.
+func parseRequestTemplate(rawURL string, header http.Header, body []byte) (*requestTemplate, error) {
+	rt := &requestTemplate{set: template.New("request").Funcs(templateFuncs).Option("missingkey=error")}
+	parse := func(name, text string) error {
+		if _, err := rt.set.New(name).Parse(text); err != nil {
+			return fmt.Errorf("invalid template: %w", err)
+		}
+		return nil
+	}
+
+	if hasTemplate(rawURL) {
+		if err := parse("url", rawURL); err != nil {
+			return nil, err
+		}
+		rt.url = true
+	}
+	for key, values := range header {
+		for i, v := range values {
+			if !hasTemplate(v) {
+				continue
+			}
+			name := fmt.Sprintf("header %d", len(rt.headers))
+			if err := parse(name, v); err != nil {
+				return nil, err
+			}
+			rt.headers = append(rt.headers, templatedHeader{key: key, index: i, name: name})
+		}
+	}
+	if hasTemplate(string(body)) {
+		if err := parse("body", string(body)); err != nil {
+			return nil, err
+		}
+		rt.body = true
+	}
+
+	if !rt.url && !rt.body && len(rt.headers) == 0 {
+		return nil, nil
+	}
+	return rt, nil
+}
+
+// templater renders request templates for one worker. It is not safe for
+// concurrent use.
+type templater struct {
+	seq, worker int                                     // of the request being rendered
+	sets        map[*requestTemplate]*template.Template // bound to seq and worker
+}
+
+func newTemplater(worker int) *templater {
+	return &templater{worker: worker, sets: map[*requestTemplate]*template.Template{}}
+}
+
+// render executes the named template of rt.
+func (tm *templater) render(rt *requestTemplate, name string, data any) ([]byte, error) {
+	set, ok := tm.sets[rt]
+	if !ok {
+		var err error
+		if set, err = rt.set.Clone(); err != nil {
+			return nil, err
+		}
+		set.Funcs(template.FuncMap{
+			"seq":      func() int { return tm.seq },
+			"workerID": func() int { return tm.worker },
+		})
+		tm.sets[rt] = set
+	}
+	var buf bytes.Buffer
+	if err := set.ExecuteTemplate(&buf, name, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// newUUID returns a random (version 4) UUID.
+func newUUID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// randInt returns a random integer in [lo, hi].
+func randInt(lo, hi int) (int, error) {
+	if hi < lo {
+		return 0, fmt.Errorf("randInt: %d is less than %d", hi, lo)
+	}
+	return lo + mathrand.IntN(hi-lo+1), nil //nolint:gosec // test data doesn't need cryptographic randomness
+}
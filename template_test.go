@@ -0,0 +1,143 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+func TestParseRequestTemplate(t *testing.T) {
+	rt, err := parseRequestTemplate("http://example.com/", http.Header{"Accept": {"*/*"}}, []byte("{}"))
+	if err != nil || rt != nil {
+		t.Errorf("expected nil for a request without templates, got %v, %v", rt, err)
+	}
+
+	rt, err = parseRequestTemplate("http://example.com/{{seq}}", http.Header{"X-Id": {"a", "{{uuid}}"}}, []byte(`{"n": {{randInt 1 5}}}`))
+	if err != nil {
+		t.Fatalf("parseRequestTemplate failed: %v", err)
+	}
+	if !rt.url || !rt.body || len(rt.headers) != 1 || rt.headers[0].index != 1 {
+		t.Errorf("expected templated url, body and second X-Id value, got %+v", rt)
+	}
+
+	for _, text := range []string{"{{seq", "{{nope}}", "{{randInt 1}}x{{"} {
+		if _, err := parseRequestTemplate("http://example.com/"+text, nil, nil); err == nil {
+			t.Errorf("expected error for %q", text)
+		}
+	}
+}
+
+func TestTemplaterRender(t *testing.T) {
+	t.Setenv("BOOP_TEST_TOKEN", "secret")
+	rt, err := parseRequestTemplate("", nil, []byte(`{{seq}} {{workerID}} {{env "BOOP_TEST_TOKEN"}} {{uuid}} {{randInt 3 3}} {{now}}`))
+	if err != nil {
+		t.Fatalf("parseRequestTemplate failed: %v", err)
+	}
+
+	tm := newTemplater(7)
+	tm.seq = 42
+	out, err := tm.render(rt, "body", nil)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	re := regexp.MustCompile(`^42 7 secret [0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12} 3 \d{4}-\d\d-\d\dT`)
+	if !re.Match(out) {
+		t.Errorf("unexpected render %q", out)
+	}
+
+	// each worker's templater is bound to its own state
+	other := newTemplater(8)
+	other.seq = 1
+	if out, _ := other.render(rt, "body", nil); !regexp.MustCompile(`^1 8 `).Match(out) {
+		t.Errorf("expected seq 1 and worker 8, got %q", out)
+	}
+}
+
+func TestRandInt(t *testing.T) {
+	seen := map[int]bool{}
+	for range 1000 {
+		n, err := randInt(1, 3)
+		if err != nil {
+			t.Fatalf("randInt failed: %v", err)
+		}
+		if n < 1 || n > 3 {
+			t.Fatalf("expected 1 to 3, got %d", n)
+		}
+		seen[n] = true
+	}
+	if len(seen) != 3 {
+		t.Errorf("expected every value to be returned, got %v", seen)
+	}
+	if _, err := randInt(5, 1); err == nil {
+		t.Error("expected error for an empty range")
+	}
+}
+
+func TestTargetRequestTemplated(t *testing.T) {
+	defaults := targetDefaults{
+		method:   "POST",
+		header:   http.Header{"X-Worker": {"{{workerID}}"}},
+		body:     []byte(`{"seq": {{seq}}}`),
+		template: true,
+	}
+	tgt, err := newTarget(targetSpec{URL: "http://example.com/items/{{seq}}"}, defaults)
+	if err != nil {
+		t.Fatalf("newTarget failed: %v", err)
+	}
+	if tgt.name != "POST http://example.com/items/{{seq}}" {
+		t.Errorf("expected the name to show the template, got %q", tgt.name)
+	}
+
+	tm := newTemplater(3)
+	for seq := range 2 {
+		req, err := tgt.request(t.Context(), tm, seq, nil)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		if req.URL.Path != "/items/"+strconv.Itoa(seq) || req.Host != "example.com" {
+			t.Errorf("expected /items/%d on example.com, got %s on %s", seq, req.URL.Path, req.Host)
+		}
+		if req.Header.Get("X-Worker") != "3" {
+			t.Errorf("expected X-Worker 3, got %q", req.Header.Get("X-Worker"))
+		}
+		body, _ := io.ReadAll(req.Body)
+		if want := `{"seq": ` + strconv.Itoa(seq) + `}`; string(body) != want || req.ContentLength != int64(len(want)) {
+			t.Errorf("expected body %s, got %s with length %d", want, body, req.ContentLength)
+		}
+	}
+	if tgt.req.Header.Get("X-Worker") != "{{workerID}}" {
+		t.Error("expected the template request to be left unchanged")
+	}
+
+	// without -template, braces are sent as is
+	defaults.template = false
+	tgt, err = newTarget(targetSpec{URL: "http://example.com/"}, defaults)
+	if err != nil {
+		t.Fatalf("newTarget failed: %v", err)
+	}
+	req, err := tgt.request(t.Context(), tm, 5, nil)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if body, _ := io.ReadAll(req.Body); string(body) != `{"seq": {{seq}}}` {
+		t.Errorf("expected the literal body, got %s", body)
+	}
+}
+
+func TestTargetRequestRenderError(t *testing.T) {
+	tgt, err := newTarget(targetSpec{URL: "http://example.com/"}, targetDefaults{
+		method: "POST", body: []byte(`{{randInt 9 1}}`), template: true,
+	})
+	if err != nil {
+		t.Fatalf("newTarget failed: %v", err)
+	}
+	_, err = tgt.request(t.Context(), newTemplater(0), 0, nil)
+	if err == nil {
+		t.Fatal("expected a render error")
+	}
+	if cat := errorCategory(err); cat != "template error" {
+		t.Errorf("expected template error, got %s", cat)
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// threshold is a pass/fail assertion on a metric of a run, e.g. p95<300ms.
+type threshold struct {
+	expr   string // as given
+	metric string
+	op     string
+	value  float64 // seconds for latency metrics, percent for errors
+}
+
+// thresholdResult is the outcome of a threshold.
+type thresholdResult struct {
+	Threshold string   `json:"threshold"`
+	Actual    *float64 `json:"actual"` // null if the metric was not measured
+	Pass      bool     `json:"pass"`
+
+	metric string
+}
+
+// thresholdSlice is for parsing repeatable -assert flags
+type thresholdSlice []threshold
+
+func (t *thresholdSlice) String() string {
+	exprs := make([]string, len(*t))
+	for i, th := range *t {
+		exprs[i] = th.expr
+	}
+	return strings.Join(exprs, ",")
+}
+
+func (t *thresholdSlice) Set(v string) error {
+	for expr := range strings.SplitSeq(v, ",") {
+		th, err := parseThreshold(expr)
+		if err != nil {
+			return err
+		}
+		*t = append(*t, th)
+	}
+	return nil
+}
+
+var (
+	thresholdRE = regexp.MustCompile(`^\s*([a-z0-9x]+)\s*(<=|>=|==|!=|<|>)\s*(\S+?)\s*$`)
+	statusRE    = regexp.MustCompile(`^([1-5]xx|[1-5][0-9][0-9])$`)
+	latencyMets = []string{"mean", "min", "max", "p10", "p25", "p50", "p75", "p90", "p95", "p99"}
+)
+
+// parseThreshold parses a threshold of the form <metric><op><value>. Metrics
+// are the latency statistics (mean, min, max, p10 … p99) compared against a
+// duration, errors (the error rate, in percent), rps, requests, and status
+// code counts, either by class (5xx) or by code (429).
+func parseThreshold(expr string) (threshold, error) {
+	m := thresholdRE.FindStringSubmatch(expr)
+	if m == nil {
+		return threshold{}, fmt.Errorf("%q is not of the form <metric><op><value>, e.g. p95<300ms", expr)
+	}
+	th := threshold{expr: strings.TrimSpace(expr), metric: m[1], op: m[2]}
+	switch {
+	case isLatencyMetric(th.metric):
+		d, err := time.ParseDuration(m[3])
+		if err != nil {
+			return threshold{}, fmt.Errorf("%q: %w", expr, err)
+		}
+		th.value = d.Seconds()
+	case th.metric == "errors", th.metric == "rps", th.metric == "requests", statusRE.MatchString(th.metric):
+		v, err := strconv.ParseFloat(strings.TrimSuffix(m[3], "%"), 64)
+		if err != nil {
+			return threshold{}, fmt.Errorf("%q: invalid value %q", expr, m[3])
+		}
+		th.value = v
+	default:
+		return threshold{}, fmt.Errorf("%q: unknown metric %q", expr, th.metric)
+	}
+	return th, nil
+}
+
+func isLatencyMetric(metric string) bool {
+	return slices.Contains(latencyMets, metric)
+}
+
+// actual returns the value of the threshold's metric in rep, and false if it
+// was not measured. Latency metrics are of the effective latency, see
+// effectiveLatency.
+func (th threshold) actual(rep *report) (float64, bool) {
+	switch th.metric {
+	case "errors":
+		return rep.ErrorRate, rep.Requests > 0
+	case "rps":
+		return rep.RequestsPerSec, rep.Requests > 0
+	case "requests":
+		return float64(rep.Requests), true
+	}
+	if statusRE.MatchString(th.metric) {
+		n := 0
+		for code, count := range rep.StatusCodes {
+			if statusMatches(code, th.metric) {
+				n += count
+			}
+		}
+		return float64(n), true
+	}
+
+	lat := effectiveLatency(rep)
+	if lat == nil {
+		return 0, false
+	}
+	return map[string]float64{
+		"mean": lat.Mean, "min": lat.Min, "max": lat.Max,
+		"p10": lat.P10, "p25": lat.P25, "p50": lat.P50, "p75": lat.P75,
+		"p90": lat.P90, "p95": lat.P95, "p99": lat.P99,
+	}[th.metric], true
+}
+
+// statusMatches reports whether code is pattern, a status code (429) or
+// class (5xx) matched by statusRE.
+func statusMatches(code int, pattern string) bool {
+	if class, ok := strings.CutSuffix(pattern, "xx"); ok {
+		return strconv.Itoa(code/100) == class
+	}
+	return strconv.Itoa(code) == pattern
+}
+
+// evaluate checks the threshold against rep. A metric that was not measured,
+// such as a percentile of a run where every request failed, fails.
+func (th threshold) evaluate(rep *report) thresholdResult {
+	res := thresholdResult{Threshold: th.expr, metric: th.metric}
+	v, ok := th.actual(rep)
+	if !ok {
+		return res
+	}
+	res.Actual = &v
+	switch th.op {
+	case "<":
+		res.Pass = v < th.value
+	case "<=":
+		res.Pass = v <= th.value
+	case ">":
+		res.Pass = v > th.value
+	case ">=":
+		res.Pass = v >= th.value
+	case "==":
+		res.Pass = v == th.value
+	case "!=":
+		res.Pass = v != th.value
+	}
+	return res
+}
+
+// evaluateThresholds checks every threshold against rep. The returned error
+// is non-nil if any failed.
+func evaluateThresholds(thresholds []threshold, rep *report) ([]thresholdResult, error) {
+	results := make([]thresholdResult, len(thresholds))
+	failed := 0
+	for i, th := range thresholds {
+		results[i] = th.evaluate(rep)
+		if !results[i].Pass {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return results, fmt.Errorf("%d of %d thresholds failed", failed, len(thresholds))
+	}
+	return results, nil
+}
+
+// thresholdTable formats threshold results as a pass/fail table.
+func thresholdTable(results []thresholdResult) string {
+	var sb strings.Builder
+	sb.WriteString("\nThresholds:\n")
+	for _, res := range results {
+		status := "PASS"
+		if !res.Pass {
+			status = "FAIL"
+		}
+		fmt.Fprintf(&sb, "  %s  %-20s %s\n", status, res.Threshold, formatActual(res))
+	}
+	return sb.String()
+}
+
+// formatActual formats the measured value of a threshold result in the unit
+// of its metric.
+func formatActual(res thresholdResult) string {
+	if res.Actual == nil {
+		return "(not measured)"
+	}
+	switch {
+	case isLatencyMetric(res.metric):
+		return fmt.Sprintf("%.4f secs", *res.Actual)
+	case res.metric == "errors":
+		return fmt.Sprintf("%.2f%%", *res.Actual)
+	case res.metric == "rps":
+		return fmt.Sprintf("%.4f", *res.Actual)
+	default:
+		return strconv.FormatFloat(*res.Actual, 'f', -1, 64)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseThreshold(t *testing.T) {
+	tests := []struct {
+		expr   string
+		metric string
+		op     string
+		value  float64
+	}{
+		{"p95<300ms", "p95", "<", 0.3},
+		{" p99 <= 1s ", "p99", "<=", 1},
+		{"errors<0.5%", "errors", "<", 0.5},
+		{"rps>1000", "rps", ">", 1000},
+		{"5xx==0", "5xx", "==", 0},
+		{"429!=0", "429", "!=", 0},
+	}
+	for _, tt := range tests {
+		th, err := parseThreshold(tt.expr)
+		if err != nil {
+			t.Errorf("parseThreshold(%q) failed: %v", tt.expr, err)
+			continue
+		}
+		if th.metric != tt.metric || th.op != tt.op || th.value != tt.value {
+			t.Errorf("parseThreshold(%q): expected %s %s %v, got %s %s %v", tt.expr, tt.metric, tt.op, tt.value, th.metric, th.op, th.value)
+		}
+	}
+
+	for _, expr := range []string{"", "p95", "p95<300", "p42<1s", "rps>fast", "6xx==0", "p95=300ms"} {
+		if _, err := parseThreshold(expr); err == nil {
+			t.Errorf("expected error for %q", expr)
+		}
+	}
+}
+
+func TestThresholdSliceSet(t *testing.T) {
+	var ts thresholdSlice
+	if err := ts.Set("p95<300ms,errors<1"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := ts.Set("5xx==0"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if len(ts) != 3 {
+		t.Fatalf("expected 3 thresholds, got %d", len(ts))
+	}
+	if ts.String() != "p95<300ms,errors<1,5xx==0" {
+		t.Errorf("expected p95<300ms,errors<1,5xx==0, got %s", ts.String())
+	}
+}
+
+func TestEvaluateThresholds(t *testing.T) {
+	rs := &resultSet{start: time.Now().Add(-time.Second), end: time.Now()}
+	for _, rec := range []record{
+		{latency: 100 * time.Millisecond, status: 200},
+		{latency: 200 * time.Millisecond, status: 200},
+		{latency: 300 * time.Millisecond, status: 503},
+		{failed: true, errMsg: "timeout", errCategory: "timeout"},
+	} {
+		rs.add(rec)
+	}
+	rep := rs.report()
+
+	var ts thresholdSlice
+	if err := ts.Set("p50<350ms,errors<=25,5xx==0,503<2,requests>=4,max<100ms"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	results, err := evaluateThresholds(ts, rep)
+	if err == nil {
+		t.Fatal("expected failed thresholds to return an error")
+	}
+	expected := []bool{true, true, false, true, true, false}
+	for i, res := range results {
+		if res.Pass != expected[i] {
+			t.Errorf("%s: expected pass=%v, got %v (actual %v)", res.Threshold, expected[i], res.Pass, *res.Actual)
+		}
+	}
+
+	table := thresholdTable(results)
+	if !strings.Contains(table, "FAIL  5xx==0") || !strings.Contains(table, "PASS  errors<=25") {
+		t.Errorf("unexpected table:\n%s", table)
+	}
+	if !strings.Contains(table, "25.00%") {
+		t.Errorf("expected error rate in table:\n%s", table)
+	}
+
+	if _, err := evaluateThresholds(ts[:2], rep); err != nil {
+		t.Errorf("expected passing thresholds, got %v", err)
+	}
+}
+
+func TestEvaluateThresholdsAllFailed(t *testing.T) {
+	rs := &resultSet{start: time.Now().Add(-time.Second), end: time.Now()}
+	rs.add(record{failed: true, errMsg: "refused", errCategory: "connection refused"})
+
+	th, err := parseThreshold("p99<1s")
+	if err != nil {
+		t.Fatalf("parseThreshold failed: %v", err)
+	}
+	res := th.evaluate(rs.report())
+	if res.Pass || res.Actual != nil {
+		t.Errorf("expected an unmeasured failure, got %+v", res)
+	}
+	if !strings.Contains(thresholdTable([]thresholdResult{res}), "(not measured)") {
+		t.Error("expected unmeasured threshold to be marked in the table")
+	}
+}
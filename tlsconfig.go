@@ -0,0 +1,112 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// tlsOptions are the TLS settings of a run, as given by flags.
+type tlsOptions struct {
+	insecure   bool
+	certFile   string // client certificate, PEM
+	keyFile    string // its private key, PEM; default certFile
+	caFile     string // CA certificates to verify the server with, PEM
+	minVersion string // 1.0, 1.1, 1.2 or 1.3
+	maxVersion string
+	ciphers    string // comma-separated cipher suite names
+	serverName string // SNI, and the name the server certificate is verified against
+	resume     bool   // resume sessions on new connections
+}
+
+// tlsVersions are the TLS versions by the names flags use.
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// newTLSConfig builds the TLS configuration of the transport.
+func newTLSConfig(opts tlsOptions) (*tls.Config, error) {
+	cfg := &tls.Config{
+		InsecureSkipVerify: opts.insecure, //nolint:gosec // User explicitly opted into insecure mode via -k flag
+		ServerName:         opts.serverName,
+	}
+	if opts.resume {
+		// a session cache lets new connections resume earlier sessions
+		cfg.ClientSessionCache = tls.NewLRUClientSessionCache(0)
+	}
+
+	if opts.certFile != "" {
+		keyFile := opts.keyFile
+		if keyFile == "" {
+			keyFile = opts.certFile // a PEM file may hold both
+		}
+		cert, err := tls.LoadX509KeyPair(opts.certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	} else if opts.keyFile != "" {
+		return nil, fmt.Errorf("key %s given without cert", opts.keyFile)
+	}
+
+	if opts.caFile != "" {
+		pem, err := os.ReadFile(opts.caFile) //nolint:gosec // User explicitly specified file path via -cacert flag
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no PEM certificates in %s", opts.caFile)
+		}
+	}
+
+	for _, v := range []struct {
+		name string
+		dst  *uint16
+	}{{opts.minVersion, &cfg.MinVersion}, {opts.maxVersion, &cfg.MaxVersion}} {
+		if v.name == "" {
+			continue
+		}
+		version, ok := tlsVersions[v.name]
+		if !ok {
+			return nil, fmt.Errorf("unknown TLS version %q, must be 1.0, 1.1, 1.2 or 1.3", v.name)
+		}
+		*v.dst = version
+	}
+	if cfg.MinVersion != 0 && cfg.MaxVersion != 0 && cfg.MinVersion > cfg.MaxVersion {
+		return nil, fmt.Errorf("TLS min version %s is above max version %s", opts.minVersion, opts.maxVersion)
+	}
+
+	if opts.ciphers != "" {
+		var err error
+		if cfg.CipherSuites, err = parseCipherSuites(opts.ciphers); err != nil {
+			return nil, err
+		}
+	}
+	return cfg, nil
+}
+
+// parseCipherSuites parses comma-separated cipher suite names, as named by
+// crypto/tls, e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256. The cipher suites
+// of TLS 1.3 are not configurable, so these only apply to TLS 1.2 and earlier.
+func parseCipherSuites(s string) ([]uint16, error) {
+	byName := map[string]uint16{}
+	for _, cs := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		byName[cs.Name] = cs.ID
+	}
+	var ids []uint16
+	for name := range strings.SplitSeq(s, ",") {
+		name = strings.TrimSpace(name)
+		id, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
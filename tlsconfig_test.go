@@ -0,0 +1,192 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testPKI is a CA, and the PEM files of certificates it issued.
+type testPKI struct {
+	t    *testing.T
+	dir  string
+	ca   *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pool *x509.CertPool
+}
+
+func newTestPKI(t *testing.T) *testPKI {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "boop test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create CA: %v", err)
+	}
+	ca, _ := x509.ParseCertificate(der)
+	p := &testPKI{t: t, dir: t.TempDir(), ca: ca, key: key, pool: x509.NewCertPool()}
+	p.pool.AddCert(ca)
+	p.write("ca.pem", "CERTIFICATE", der)
+	return p
+}
+
+func (p *testPKI) write(name, blockType string, der []byte) string {
+	path := filepath.Join(p.dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600); err != nil {
+		p.t.Fatalf("failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+// issue returns a certificate for name, and the paths of its certificate and
+// key files.
+func (p *testPKI) issue(name string, usage x509.ExtKeyUsage) (tls.Certificate, string, string) {
+	p.t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		p.t.Fatalf("failed to generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, p.ca, &key.PublicKey, p.key)
+	if err != nil {
+		p.t.Fatalf("failed to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		p.t.Fatalf("failed to marshal key: %v", err)
+	}
+	certPath := p.write(name+".pem", "CERTIFICATE", der)
+	keyPath := p.write(name+"-key.pem", "EC PRIVATE KEY", keyDER)
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		p.t.Fatalf("failed to load certificate: %v", err)
+	}
+	return cert, certPath, keyPath
+}
+
+func TestMutualTLS(t *testing.T) {
+	pki := newTestPKI(t)
+	serverCert, _, _ := pki.issue("api.internal", x509.ExtKeyUsageServerAuth)
+	_, clientCertPath, clientKeyPath := pki.issue("boop", x509.ExtKeyUsageClientAuth)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	srv.TLS = &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pki.pool,
+	}
+	srv.StartTLS()
+	defer srv.Close()
+
+	get := func(opts tlsOptions) error {
+		cfg, err := newTLSConfig(opts)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: cfg}, Timeout: 5 * time.Second}
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			return err
+		}
+		_ = resp.Body.Close()
+		return nil
+	}
+
+	caPath := filepath.Join(pki.dir, "ca.pem")
+	// the server's certificate is for api.internal, not 127.0.0.1
+	if err := get(tlsOptions{certFile: clientCertPath, keyFile: clientKeyPath, caFile: caPath}); err == nil {
+		t.Error("expected verification to fail without -sni")
+	}
+	if err := get(tlsOptions{certFile: clientCertPath, keyFile: clientKeyPath, caFile: caPath, serverName: "api.internal"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := get(tlsOptions{caFile: caPath, serverName: "api.internal"}); err == nil {
+		t.Error("expected the server to reject a client without a certificate")
+	}
+	if err := get(tlsOptions{certFile: clientCertPath, keyFile: clientKeyPath, serverName: "api.internal"}); err == nil {
+		t.Error("expected verification to fail with the system CAs")
+	}
+
+	// a single file may hold the certificate and key
+	combined := filepath.Join(pki.dir, "combined.pem")
+	certPEM, _ := os.ReadFile(clientCertPath)
+	keyPEM, _ := os.ReadFile(clientKeyPath)
+	if err := os.WriteFile(combined, append(certPEM, keyPEM...), 0600); err != nil {
+		t.Fatalf("failed to write combined file: %v", err)
+	}
+	if err := get(tlsOptions{certFile: combined, caFile: caPath, serverName: "api.internal"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	cfg, err := newTLSConfig(tlsOptions{
+		insecure:   true,
+		minVersion: "1.2",
+		maxVersion: "1.3",
+		ciphers:    "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256",
+		serverName: "api.internal",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cfg.InsecureSkipVerify || cfg.ServerName != "api.internal" {
+		t.Errorf("expected insecure with server name api.internal, got %v and %q", cfg.InsecureSkipVerify, cfg.ServerName)
+	}
+	if cfg.MinVersion != tls.VersionTLS12 || cfg.MaxVersion != tls.VersionTLS13 {
+		t.Errorf("expected TLS 1.2 to 1.3, got %x to %x", cfg.MinVersion, cfg.MaxVersion)
+	}
+	want := []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256}
+	if len(cfg.CipherSuites) != 2 || cfg.CipherSuites[0] != want[0] || cfg.CipherSuites[1] != want[1] {
+		t.Errorf("expected cipher suites %v, got %v", want, cfg.CipherSuites)
+	}
+
+	notPEM := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	for name, opts := range map[string]tlsOptions{
+		"unknown version":   {minVersion: "1.4"},
+		"min above max":     {minVersion: "1.3", maxVersion: "1.2"},
+		"unknown cipher":    {ciphers: "TLS_NOPE"},
+		"key without cert":  {keyFile: "key.pem"},
+		"missing cert file": {certFile: filepath.Join(t.TempDir(), "missing.pem")},
+		"CA file not PEM":   {caFile: notPEM},
+	} {
+		if _, err := newTLSConfig(opts); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
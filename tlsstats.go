@@ -0,0 +1,161 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+// tlsSession is the TLS session a request was sent on. It is zero for
+// requests that were not sent over TLS.
+type tlsSession struct {
+	version uint16
+	cipher  uint16
+	alpn    string // negotiated protocol, e.g. h2; empty if none
+	resumed bool   // the handshake resumed an earlier session
+}
+
+func newTLSSession(cs *tls.ConnectionState) tlsSession {
+	if cs == nil {
+		return tlsSession{}
+	}
+	return tlsSession{version: cs.Version, cipher: cs.CipherSuite, alpn: cs.NegotiatedProtocol, resumed: cs.DidResume}
+}
+
+// versionName returns the name of the session's TLS version, or "" without TLS.
+func (s tlsSession) versionName() string {
+	if s.version == 0 {
+		return ""
+	}
+	return tls.VersionName(s.version)
+}
+
+// cipherName returns the name of the session's cipher suite, or "" without TLS.
+func (s tlsSession) cipherName() string {
+	if s.version == 0 {
+		return ""
+	}
+	return tls.CipherSuiteName(s.cipher)
+}
+
+// tlsStats aggregates the TLS handshakes of successful requests: those that
+// opened a new connection. Requests on reused connections did not pay for a
+// handshake, so they are left out.
+type tlsStats struct {
+	handshakes int
+	resumed    int
+	versions   map[string]int
+	ciphers    map[string]int
+	alpn       map[string]int
+	full       *histogram // duration of full handshakes
+	abbr       *histogram // duration of resumed handshakes
+	total      time.Duration
+}
+
+func newTLSStats(digits int) *tlsStats {
+	return &tlsStats{
+		versions: map[string]int{},
+		ciphers:  map[string]int{},
+		alpn:     map[string]int{},
+		full:     newHistogram(digits),
+		abbr:     newHistogram(digits),
+	}
+}
+
+func (s *tlsStats) add(rec record) {
+	handshake := rec.phases[phaseTLS]
+	if rec.failed || rec.tls.version == 0 || handshake == 0 {
+		return
+	}
+	s.handshakes++
+	s.versions[rec.tls.versionName()]++
+	s.ciphers[rec.tls.cipherName()]++
+	alpn := rec.tls.alpn
+	if alpn == "" {
+		alpn = "none"
+	}
+	s.alpn[alpn]++
+	s.total += handshake
+	if rec.tls.resumed {
+		s.resumed++
+		s.abbr.record(handshake)
+	} else {
+		s.full.record(handshake)
+	}
+}
+
+// tlsReport is the TLS handshakes of a run.
+type tlsReport struct {
+	Handshakes       int            `json:"handshakes"`
+	Resumed          int            `json:"resumed"`
+	Versions         map[string]int `json:"versions"`
+	CipherSuites     map[string]int `json:"cipher_suites"`
+	ALPN             map[string]int `json:"alpn"`
+	FullHandshake    *latencyReport `json:"full_handshake,omitempty"`
+	ResumedHandshake *latencyReport `json:"resumed_handshake,omitempty"`
+	LatencyShare     float64        `json:"latency_share_percent"` // of the total latency of successful requests
+}
+
+// report computes the statistics of the handshakes, given the latency
+// distribution of the successful requests they were part of.
+func (s *tlsStats) report(latency *histogram) *tlsReport {
+	if s.handshakes == 0 {
+		return nil
+	}
+	rep := &tlsReport{
+		Handshakes:   s.handshakes,
+		Resumed:      s.resumed,
+		Versions:     maps.Clone(s.versions),
+		CipherSuites: maps.Clone(s.ciphers),
+		ALPN:         maps.Clone(s.alpn),
+	}
+	if s.full.count() > 0 {
+		rep.FullHandshake = newLatencyReport(s.full)
+	}
+	if s.abbr.count() > 0 {
+		rep.ResumedHandshake = newLatencyReport(s.abbr)
+	}
+	if total := latency.mean() * time.Duration(latency.count()); total > 0 {
+		rep.LatencyShare = 100 * float64(s.total) / float64(total)
+	}
+	return rep
+}
+
+// tlsSummary formats the TLS handshake report.
+func tlsSummary(rep *tlsReport) string {
+	var sb strings.Builder
+	sb.WriteString("\nTLS handshakes:\n")
+	fmt.Fprintf(&sb, "  %d handshakes, %d resumed (%.2f%%), %.2f%% of total latency\n",
+		rep.Handshakes, rep.Resumed, 100*float64(rep.Resumed)/float64(rep.Handshakes), rep.LatencyShare)
+	for _, h := range []struct {
+		name string
+		lat  *latencyReport
+	}{{"Full:", rep.FullHandshake}, {"Resumed:", rep.ResumedHandshake}} {
+		if h.lat != nil {
+			fmt.Fprintf(&sb, "  %-14s average %.4f secs, 50%% in %.4f secs, 90%% in %.4f secs, 99%% in %.4f secs\n",
+				h.name, h.lat.Mean, h.lat.P50, h.lat.P90, h.lat.P99)
+		}
+	}
+	fmt.Fprintf(&sb, "  %-14s %s\n", "Versions:", counts(rep.Versions))
+	fmt.Fprintf(&sb, "  %-14s %s\n", "Ciphers:", counts(rep.CipherSuites))
+	fmt.Fprintf(&sb, "  %-14s %s\n", "ALPN:", counts(rep.ALPN))
+	return sb.String()
+}
+
+// counts formats counts by name, most frequent first.
+func counts(m map[string]int) string {
+	names := slices.SortedFunc(maps.Keys(m), func(a, b string) int {
+		if m[a] != m[b] {
+			return m[b] - m[a]
+		}
+		return strings.Compare(a, b)
+	})
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%s [%d]", name, m[name])
+	}
+	return strings.Join(parts, ", ")
+}
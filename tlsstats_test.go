@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTLSSessions(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	for _, resume := range []bool{false, true} {
+		cfg, err := newTLSConfig(tlsOptions{insecure: true, resume: resume})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		// a new connection, and so a handshake, for every request
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: cfg, DisableKeepAlives: true}}
+		tgt, err := newTarget(targetSpec{URL: srv.URL}, targetDefaults{method: "GET"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		rs := &resultSet{start: time.Now()}
+		for range 3 {
+			req, err := tgt.request(t.Context(), newTemplater(0), 0, nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			rec, _, _ := send(t.Context(), client, req, record{sent: time.Now()}, false, false)
+			if rec.failed {
+				t.Fatalf("unexpected failure: %s", rec.errMsg)
+			}
+			if rec.tls.versionName() != "TLS 1.3" || rec.tls.cipherName() == "" || rec.phases[phaseTLS] == 0 {
+				t.Errorf("expected a TLS 1.3 handshake, got %+v", rec.tls)
+			}
+			rs.add(rec)
+		}
+		rs.end = time.Now()
+
+		rep := rs.report().TLS
+		if rep == nil || rep.Handshakes != 3 || rep.Versions["TLS 1.3"] != 3 || rep.ALPN["none"] != 3 {
+			t.Fatalf("expected 3 TLS 1.3 handshakes without ALPN, got %+v", rep)
+		}
+		// the first handshake is always full
+		wantResumed := 0
+		if resume {
+			wantResumed = 2
+		}
+		if rep.Resumed != wantResumed || rep.FullHandshake.Count != int64(3-wantResumed) {
+			t.Errorf("tls-resume %v: expected %d resumed handshakes, got %d of %d full", resume, wantResumed, rep.Resumed, rep.FullHandshake.Count)
+		}
+		if rep.LatencyShare <= 0 || rep.LatencyShare > 100 {
+			t.Errorf("expected handshakes to be a share of latency, got %.2f%%", rep.LatencyShare)
+		}
+	}
+}
+
+func TestTLSSummary(t *testing.T) {
+	rs := &resultSet{start: time.Now().Add(-time.Second), end: time.Now()}
+	tls13 := tlsSession{version: 0x0304, cipher: 0x1301, alpn: "h2"}
+	resumed := tls13
+	resumed.resumed = true
+	var phases [numPhases]time.Duration
+	phases[phaseTLS] = 10 * time.Millisecond
+	rs.add(record{latency: 40 * time.Millisecond, status: 200, tls: tls13, phases: phases})
+	phases[phaseTLS] = 2 * time.Millisecond
+	rs.add(record{latency: 40 * time.Millisecond, status: 200, tls: resumed, phases: phases})
+	// reused connections and failed requests made no counted handshake
+	rs.add(record{latency: 20 * time.Millisecond, status: 200, tls: tls13})
+	rs.add(record{latency: 20 * time.Millisecond, failed: true, errMsg: "timeout", tls: tls13, phases: phases})
+
+	var out bytes.Buffer
+	rs.report().writeText(&out)
+	for _, want := range []string{
+		"2 handshakes, 1 resumed (50.00%), 12.00% of total latency",
+		"Full:          average 0.0100 secs",
+		"Resumed:       average 0.0020 secs",
+		"Versions:      TLS 1.3 [2]",
+		"Ciphers:       TLS_AES_128_GCM_SHA256 [2]",
+		"ALPN:          h2 [2]",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected %q in output:\n%s", want, out.String())
+		}
+	}
+}
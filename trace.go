@@ -0,0 +1,109 @@
+package main
+
+import (
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// phase is a part of the timeline of a single request.
+type phase int
+
+const (
+	phaseDNS      phase = iota // DNS lookup
+	phaseConnect               // TCP connect
+	phaseTLS                   // TLS handshake
+	phaseWrite                 // from getting a connection to writing the request
+	phaseTTFB                  // from the start of the request to the first response byte
+	phaseTransfer              // from the first response byte to the end of the body
+	numPhases
+)
+
+var phaseNames = [numPhases]string{
+	phaseDNS:      "DNS lookup",
+	phaseConnect:  "TCP connect",
+	phaseTLS:      "TLS handshake",
+	phaseWrite:    "req write",
+	phaseTTFB:     "TTFB",
+	phaseTransfer: "resp read",
+}
+
+// phaseTimer times the phases of a request using httptrace hooks. Hooks for
+// dialing may run on other goroutines, and even after the request has been
+// given another connection, so the timer is safe for concurrent use.
+type phaseTimer struct {
+	mu                               sync.Mutex
+	start                            time.Time
+	dnsStart, connectStart, tlsStart time.Time
+	gotConn, wroteRequest, firstByte time.Time
+	dns, connect, handshake          time.Duration
+	reused                           bool // the connection was used before
+}
+
+func newPhaseTimer(start time.Time) *phaseTimer {
+	return &phaseTimer{start: start}
+}
+
+// trace returns hooks that feed the timer. onConn, if not nil, is also
+// called when a connection is obtained.
+func (p *phaseTimer) trace(onConn func(httptrace.GotConnInfo)) *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart:          func(httptrace.DNSStartInfo) { p.mark(&p.dnsStart) },
+		DNSDone:           func(httptrace.DNSDoneInfo) { p.since(&p.dns, &p.dnsStart) },
+		ConnectStart:      func(string, string) { p.mark(&p.connectStart) },
+		ConnectDone:       func(string, string, error) { p.since(&p.connect, &p.connectStart) },
+		TLSHandshakeStart: func() { p.mark(&p.tlsStart) },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { p.since(&p.handshake, &p.tlsStart) },
+		GotConn: func(ci httptrace.GotConnInfo) {
+			p.mark(&p.gotConn)
+			p.mu.Lock()
+			p.reused = ci.Reused
+			p.mu.Unlock()
+			if onConn != nil {
+				onConn(ci)
+			}
+		},
+		WroteRequest:         func(httptrace.WroteRequestInfo) { p.mark(&p.wroteRequest) },
+		GotFirstResponseByte: func() { p.mark(&p.firstByte) },
+	}
+}
+
+func (p *phaseTimer) mark(t *time.Time) {
+	p.mu.Lock()
+	*t = time.Now()
+	p.mu.Unlock()
+}
+
+func (p *phaseTimer) since(d *time.Duration, start *time.Time) {
+	p.mu.Lock()
+	*d = time.Since(*start)
+	p.mu.Unlock()
+}
+
+// done returns the duration of each phase for a request whose body was fully
+// read at end. Phases that did not happen, such as dialing on a reused
+// connection, are zero.
+func (p *phaseTimer) done(end time.Time) [numPhases]time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var phases [numPhases]time.Duration
+	phases[phaseDNS] = p.dns
+	phases[phaseConnect] = p.connect
+	phases[phaseTLS] = p.handshake
+	if !p.gotConn.IsZero() && !p.wroteRequest.IsZero() {
+		phases[phaseWrite] = p.wroteRequest.Sub(p.gotConn)
+	}
+	if !p.firstByte.IsZero() {
+		phases[phaseTTFB] = p.firstByte.Sub(p.start)
+		phases[phaseTransfer] = end.Sub(p.firstByte)
+	}
+	return phases
+}
+
+// connReused reports whether the request was sent on a reused connection.
+func (p *phaseTimer) connReused() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.reused
+}
@@ -14,16 +14,17 @@ func worker(
 	ctx context.Context,
 	id int,
 	client *http.Client,
-	reqTpl *http.Request,
-	jobCh <-chan int,
-	out *resultSet,
+	targets *targetSet,
+	jobCh <-chan job,
+	out recorder,
 	wg *sync.WaitGroup,
 	limiter <-chan time.Time,
 	withTrace bool,
 ) {
 	defer wg.Done()
+	tm := newTemplater(id)
 
-	for range jobCh { // each value of jobCh a placeholder index
+	for j := range jobCh {
 		// Check if context is done before processing
 		select {
 		case <-ctx.Done():
@@ -32,47 +33,96 @@ func worker(
 			// Continue processing
 		}
 
+		intended := j.intended
 		if limiter != nil {
 			select {
-			case <-limiter:
-				// Rate limiting
+			case intended = <-limiter:
+				// Rate limiting; the tick is when the request should be sent
 			case <-ctx.Done():
 				return // Exit if context was canceled while waiting
 			}
 		}
 
-		// Clone request (cheap shallow copy, new body)
-		req := reqTpl.Clone(ctx)
-		if req.Body != nil {
-			_ = req.Body.Close() // close old (no‑op for NopCloser)
-			req.Body, _ = reqTpl.GetBody()
-		}
-
-		start := time.Now()
-		var rec record
-
-		if withTrace {
-			trace := &httptrace.ClientTrace{
-				GotConn: func(ci httptrace.GotConnInfo) {
-					fmt.Printf("worker %d got conn: reused=%v idle=%v\n", id, ci.Reused, ci.WasIdle)
-				},
-			}
-			req = req.WithContext(httptrace.WithClientTrace(ctx, trace))
+		if targets.scenario != nil {
+			targets.scenario.run(ctx, client, tm, j, intended, id, out, withTrace)
+			continue
 		}
 
-		resp, err := client.Do(req)
+		idx, req, err := targets.request(ctx, tm, j.seq)
+		rec := newRecord(j, idx, id, intended)
 		if err != nil {
-			rec.failed = true
-			rec.errMsg = err.Error()
+			// the request could not be rendered, so it was never sent
+			rec.fail(err)
 			out.add(rec)
 			continue
 		}
-		n, _ := io.Copy(io.Discard, resp.Body) // drain body
-		_ = resp.Body.Close()
+		t := targets.targets[idx]
+		rec, resp, body := send(ctx, client, req, rec, withTrace, checksNeedBody(t.checks))
+		rec.verify(t, resp, body)
+		out.add(rec)
+	}
+}
+
+// newRecord starts the record of a request of job j to the idx-th target,
+// sent now by worker.
+func newRecord(j job, idx, worker int, intended time.Time) record {
+	start := time.Now()
+	rec := record{stage: j.stage, target: idx, sent: start, worker: worker}
+	if !intended.IsZero() && start.After(intended) {
+		rec.wait = start.Sub(intended) // time spent behind schedule
+	}
+	return rec
+}
+
+// fail marks rec as failed by err.
+func (rec *record) fail(err error) {
+	rec.failed = true
+	rec.errMsg = err.Error()
+	rec.errCategory = errorCategory(err)
+}
+
+// send sends req and completes rec with its response. The response body is
+// drained, and returned if keepBody is set; the response is nil if the
+// request failed.
+func send(ctx context.Context, client *http.Client, req *http.Request, rec record, withTrace, keepBody bool) (record, *http.Response, []byte) {
+	// time each phase of the request, and print connection info if asked
+	var onConn func(httptrace.GotConnInfo)
+	if withTrace {
+		onConn = func(ci httptrace.GotConnInfo) {
+			fmt.Printf("worker %d got conn: reused=%v idle=%v\n", rec.worker, ci.Reused, ci.WasIdle)
+		}
+	}
+	start := rec.sent
+	timer := newPhaseTimer(start)
+	req = req.WithContext(httptrace.WithClientTrace(ctx, timer.trace(onConn)))
 
+	resp, err := client.Do(req)
+	if err != nil {
 		rec.latency = time.Since(start)
-		rec.status = resp.StatusCode
-		rec.size = n
-		out.add(rec)
+		rec.fail(err)
+		return rec, nil, nil
+	}
+	var (
+		body []byte
+		n    int64
+	)
+	if keepBody {
+		body, err = io.ReadAll(resp.Body)
+		n = int64(len(body))
+	} else {
+		n, err = io.Copy(io.Discard, resp.Body) // drain body
+	}
+	_ = resp.Body.Close()
+
+	end := time.Now()
+	rec.latency = end.Sub(start)
+	rec.phases = timer.done(end)
+	rec.reused = timer.connReused()
+	rec.tls = newTLSSession(resp.TLS)
+	rec.status = resp.StatusCode
+	rec.size = n
+	if err != nil && keepBody {
+		rec.fail(err) // the body is needed, so a partial one is a failure
 	}
+	return rec, resp, body
 }
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"strings"
 	"sync"
 	"testing"
@@ -28,6 +29,18 @@ func (d dummyRoundTripper) RoundTrip(req *http.Request) (*http.Response, error)
 	}, nil
 }
 
+// recordLog is a recorder that keeps every record for inspection.
+type recordLog struct {
+	mu      sync.Mutex
+	records []record
+}
+
+func (l *recordLog) add(rec record) {
+	l.mu.Lock()
+	l.records = append(l.records, rec)
+	l.mu.Unlock()
+}
+
 // bodyCheckTransport is a test transport that verifies request body
 type bodyCheckTransport struct {
 	t            *testing.T
@@ -65,20 +78,20 @@ func TestWorkerSuccess(t *testing.T) {
 	}
 
 	// Set up channels and result set.
-	jobCh := make(chan int, 3)
+	jobCh := make(chan job, 3)
 	var wg sync.WaitGroup
-	results := &resultSet{}
+	results := &recordLog{}
 
 	ctx, cancel := context.WithCancel(t.Context())
 	defer cancel()
 
 	// Start one worker.
 	wg.Add(1)
-	go worker(ctx, 1, client, reqTpl, jobCh, results, &wg, nil, false)
+	go worker(ctx, 1, client, singleTarget(reqTpl), jobCh, results, &wg, nil, false)
 
 	// Send 3 jobs.
 	for i := range 3 {
-		jobCh <- i
+		jobCh <- job{seq: i}
 	}
 	close(jobCh)
 	wg.Wait()
@@ -109,20 +122,20 @@ func TestWorkerFailure(t *testing.T) {
 	}
 
 	// Set up channels and result set.
-	jobCh := make(chan int, 2)
+	jobCh := make(chan job, 2)
 	var wg sync.WaitGroup
-	results := &resultSet{}
+	results := &recordLog{}
 
 	ctx, cancel := context.WithCancel(t.Context())
 	defer cancel()
 
 	// Start one worker.
 	wg.Add(1)
-	go worker(ctx, 2, client, reqTpl, jobCh, results, &wg, nil, false)
+	go worker(ctx, 2, client, singleTarget(reqTpl), jobCh, results, &wg, nil, false)
 
 	// Send 2 jobs.
 	for i := range 2 {
-		jobCh <- i
+		jobCh <- job{seq: i}
 	}
 	close(jobCh)
 	wg.Wait()
@@ -138,6 +151,9 @@ func TestWorkerFailure(t *testing.T) {
 		if rec.errMsg == "" {
 			t.Error("expected an error message but got empty")
 		}
+		if rec.errCategory != "other" {
+			t.Errorf("expected error category 'other', got %q", rec.errCategory)
+		}
 	}
 }
 
@@ -153,20 +169,20 @@ func TestWorkerContextCancellation(t *testing.T) {
 	}
 
 	// Create a buffered channel that won't block
-	jobCh := make(chan int, 100)
+	jobCh := make(chan job, 100)
 	var wg sync.WaitGroup
-	results := &resultSet{}
+	results := &recordLog{}
 
 	// Create a context we can cancel
 	ctx, cancel := context.WithCancel(t.Context())
 
 	// Start the worker
 	wg.Add(1)
-	go worker(ctx, 1, client, reqTpl, jobCh, results, &wg, nil, false)
+	go worker(ctx, 1, client, singleTarget(reqTpl), jobCh, results, &wg, nil, false)
 
 	// Send a few jobs to ensure worker is running
 	for i := range 3 {
-		jobCh <- i
+		jobCh <- job{seq: i}
 	}
 
 	// Small delay to ensure worker processes some jobs
@@ -177,7 +193,7 @@ func TestWorkerContextCancellation(t *testing.T) {
 
 	// Send more jobs that should be ignored
 	for i := 3; i < 10; i++ {
-		jobCh <- i
+		jobCh <- job{seq: i}
 	}
 
 	// Close job channel and wait for worker to exit
@@ -201,9 +217,9 @@ func TestWorkerRateLimiting(t *testing.T) {
 		t.Fatalf("failed to create request template: %v", err)
 	}
 
-	jobCh := make(chan int, 5)
+	jobCh := make(chan job, 5)
 	var wg sync.WaitGroup
-	results := &resultSet{}
+	results := &recordLog{}
 
 	ctx, cancel := context.WithCancel(t.Context())
 	defer cancel()
@@ -213,11 +229,11 @@ func TestWorkerRateLimiting(t *testing.T) {
 
 	// Start the worker with our controlled limiter
 	wg.Add(1)
-	go worker(ctx, 1, client, reqTpl, jobCh, results, &wg, limiterCh, false)
+	go worker(ctx, 1, client, singleTarget(reqTpl), jobCh, results, &wg, limiterCh, false)
 
 	// Send jobs but don't release the limiter yet
 	for i := range 5 {
-		jobCh <- i
+		jobCh <- job{seq: i}
 	}
 	close(jobCh)
 
@@ -282,20 +298,20 @@ func TestWorkerWithBody(t *testing.T) {
 		t.Fatalf("failed to create request template: %v", err)
 	}
 
-	jobCh := make(chan int, 2)
+	jobCh := make(chan job, 2)
 	var wg sync.WaitGroup
-	results := &resultSet{}
+	results := &recordLog{}
 
 	ctx, cancel := context.WithCancel(t.Context())
 	defer cancel()
 
 	// Start worker
 	wg.Add(1)
-	go worker(ctx, 1, client, reqTpl, jobCh, results, &wg, nil, false)
+	go worker(ctx, 1, client, singleTarget(reqTpl), jobCh, results, &wg, nil, false)
 
 	// Send two jobs to test body reuse
-	jobCh <- 1
-	jobCh <- 2
+	jobCh <- job{seq: 1}
+	jobCh <- job{seq: 2}
 
 	close(jobCh)
 	wg.Wait()
@@ -310,3 +326,99 @@ func TestWorkerWithBody(t *testing.T) {
 		}
 	}
 }
+
+// TestWorkerRecordsScheduleWait tests that time spent behind schedule is recorded separately from latency
+func TestWorkerRecordsScheduleWait(t *testing.T) {
+	client := &http.Client{
+		Transport: dummyRoundTripper{fail: false},
+		Timeout:   5 * time.Second,
+	}
+	reqTpl, err := http.NewRequestWithContext(t.Context(), http.MethodGet, "http://example.com", nil)
+	if err != nil {
+		t.Fatalf("failed to create request template: %v", err)
+	}
+
+	jobCh := make(chan job, 2)
+	var wg sync.WaitGroup
+	results := &recordLog{}
+
+	wg.Add(1)
+	go worker(t.Context(), 1, client, singleTarget(reqTpl), jobCh, results, &wg, nil, false)
+
+	// One job that is already a second late, and one without a schedule
+	jobCh <- job{seq: 0, intended: time.Now().Add(-time.Second)}
+	jobCh <- job{seq: 1}
+	close(jobCh)
+	wg.Wait()
+
+	if len(results.records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(results.records))
+	}
+	if results.records[0].wait < time.Second {
+		t.Errorf("expected wait of at least 1s, got %s", results.records[0].wait)
+	}
+	if results.records[1].wait != 0 {
+		t.Errorf("expected no wait for unpaced job, got %s", results.records[1].wait)
+	}
+}
+
+// TestWorkerRecordsPhases tests that the phases of each request are timed
+func TestWorkerRecordsPhases(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("OK"))
+	}))
+	defer server.Close()
+
+	reqTpl, err := http.NewRequestWithContext(t.Context(), http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatalf("failed to create request template: %v", err)
+	}
+
+	jobCh := make(chan job, 2)
+	var wg sync.WaitGroup
+	results := &recordLog{}
+
+	wg.Add(1)
+	go worker(t.Context(), 1, server.Client(), singleTarget(reqTpl), jobCh, results, &wg, nil, false)
+	jobCh <- job{seq: 0}
+	jobCh <- job{seq: 1}
+	close(jobCh)
+	wg.Wait()
+
+	if len(results.records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(results.records))
+	}
+
+	// The first request dials a new connection
+	first := results.records[0].phases
+	for _, ph := range []phase{phaseConnect, phaseTLS, phaseWrite, phaseTTFB} {
+		if first[ph] <= 0 {
+			t.Errorf("expected %s to be timed for a new connection, got %s", phaseNames[ph], first[ph])
+		}
+	}
+	if first[phaseTTFB] > results.records[0].latency {
+		t.Errorf("TTFB %s cannot exceed latency %s", first[phaseTTFB], results.records[0].latency)
+	}
+
+	// The second reuses it
+	second := results.records[1].phases
+	if second[phaseConnect] != 0 || second[phaseTLS] != 0 {
+		t.Errorf("expected no dialing on a reused connection, got connect %s, TLS %s", second[phaseConnect], second[phaseTLS])
+	}
+	if second[phaseTTFB] <= 0 {
+		t.Errorf("expected TTFB to be timed, got %s", second[phaseTTFB])
+	}
+	if results.records[0].reused || !results.records[1].reused {
+		t.Errorf("expected only the second connection to be reused, got %v and %v", results.records[0].reused, results.records[1].reused)
+	}
+	for _, rec := range results.records {
+		if rec.worker != 1 || rec.sent.IsZero() {
+			t.Errorf("expected worker 1 and a send time, got %d and %s", rec.worker, rec.sent)
+		}
+	}
+}
+
+// singleTarget returns a target set that always picks req.
+func singleTarget(req *http.Request) *targetSet {
+	return newTargetSet([]*target{{name: "test", req: req, weight: 1}})
+}